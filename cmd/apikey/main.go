@@ -0,0 +1,46 @@
+// Apikey generates an API key for a client. The key is printed to stderr for
+// handing to the client, and the entry for the server's key file to stdout.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+)
+
+func main() {
+	var clientID, name, scopeList, tenant string
+	flag.StringVar(&clientID, "client", "", "client id the key identifies")
+	flag.StringVar(&name, "name", "", "optional display name for the client")
+	flag.StringVar(&scopeList, "scopes", "", "comma separated scopes granted to the key, e.g. admin or receipts:review,rewards:write")
+	flag.StringVar(&tenant, "tenant", "", "optional tenant the key is restricted to")
+	flag.Parse()
+
+	if clientID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	key, err := auth.GenerateKey()
+	if err != nil {
+		log.Fatalf("error generating key: %v", err)
+	}
+
+	var scopes []string
+	if scopeList != "" {
+		scopes = strings.Split(scopeList, ",")
+	}
+
+	entry, err := json.MarshalIndent(auth.APIKey{ClientID: clientID, Name: name, Hash: auth.HashKey(key), Scopes: scopes, Tenant: tenant}, "", "  ")
+	if err != nil {
+		log.Fatalf("error marshalling key entry: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "API key for %s: %s\n", clientID, key)
+	fmt.Println(string(entry))
+}
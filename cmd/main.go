@@ -2,55 +2,290 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/config"
 	"github.com/gpayne44/fetch-challenge/internal/controllers"
+	"github.com/gpayne44/fetch-challenge/internal/expiration"
+	"github.com/gpayne44/fetch-challenge/internal/health"
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+	"github.com/gpayne44/fetch-challenge/internal/metrics"
+	"github.com/gpayne44/fetch-challenge/internal/middleware"
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/ratelimit"
 	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"github.com/gpayne44/fetch-challenge/internal/tenants"
+	"github.com/gpayne44/fetch-challenge/internal/tlsreload"
+	"github.com/gpayne44/fetch-challenge/internal/tracing"
 )
 
 func main() {
-	m := repositories.New()
-	c := controllers.New(m)
+	configFlags := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := configFlags.Load(os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid config:", err)
+		os.Exit(2)
+	}
+	if configFlags.PrintConfig() {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(cfg)
+		return
+	}
+
+	policy := expiration.Policy{AfterMonths: cfg.ExpireAfterMonths, InactivityMonths: cfg.ExpireInactiveMonths}
+	limits := controllers.RequestLimits{
+		MaxBodyBytes:          cfg.MaxBodyBytes,
+		DisallowUnknownFields: cfg.StrictJSON,
+		MaxItems:              cfg.MaxItems,
+		MaxStringLength:       cfg.MaxStringLength,
+	}
+	traceConfig := tracing.Config{
+		Exporter:    cfg.TraceExporter,
+		File:        cfg.TraceFile,
+		ServiceName: "receipt-processor",
+		SampleRatio: cfg.TraceSampleRatio,
+	}
+
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
+
+	ruleset := process.DefaultRuleset()
+	if cfg.Rules != "" {
+		ruleset, err = process.LoadRuleset(cfg.Rules)
+		if err != nil {
+			fatal(logger, "error loading ruleset", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), traceConfig)
+	if err != nil {
+		fatal(logger, "error setting up tracing", err)
+	}
+
+	serviceMetrics := metrics.New()
+	opts := []controllers.Option{
+		controllers.WithRuleset(ruleset),
+		controllers.WithExpirationPolicy(policy),
+		controllers.WithRequestLimits(limits),
+		controllers.WithLogger(logger),
+		controllers.WithMetrics(serviceMetrics),
+	}
+	if traceConfig.Exporter != tracing.ExporterNone {
+		opts = append(opts, controllers.WithTracing())
+	}
+	// m is the store for a single program. With tenants each has its own
+	// store and m is left nil, since it would never be used.
+	var (
+		m      repositories.Repository
+		stores []repositories.Repository
+	)
+	checker := health.New()
+	checker.Add("ruleset", health.RulesetCheck(ruleset))
+
+	if cfg.Tenants != "" {
+		ts, err := tenants.Load(cfg.Tenants)
+		if err != nil {
+			fatal(logger, "error loading tenants", err)
+		}
+		for _, t := range ts {
+			var rs *process.Ruleset
+			if t.Rules != "" {
+				rs, err = process.LoadRuleset(t.Rules)
+				if err != nil {
+					fatal(logger.With("tenant", t.ID), "error loading tenant ruleset", err)
+				}
+				checker.Add("ruleset:"+t.ID, health.RulesetCheck(rs))
+			}
+			store, err := newStore(cfg.Storage)
+			if err != nil {
+				fatal(logger.With("tenant", t.ID), "error opening tenant storage", err)
+			}
+			store = serviceMetrics.InstrumentRepository(t.ID, store)
+			stores = append(stores, store)
+			opts = append(opts, controllers.WithTenant(t.ID, store, rs))
+			checker.Add("repository:"+t.ID, health.RepositoryCheck(store))
+		}
+	} else {
+		store, err := newStore(cfg.Storage)
+		if err != nil {
+			fatal(logger, "error opening storage", err)
+		}
+		m = serviceMetrics.InstrumentRepository("", store)
+		stores = append(stores, m)
+		checker.Add("repository", health.RepositoryCheck(m))
+	}
+
+	var authenticators []auth.Authenticator
+	if cfg.TLSClientCA != "" {
+		certs, err := auth.NewClientCerts(nil)
+		if cfg.ClientCerts != "" {
+			certs, err = auth.LoadClientCerts(cfg.ClientCerts)
+		}
+		if err != nil {
+			fatal(logger, "error loading client certificates", err)
+		}
+		authenticators = append(authenticators, certs)
+	}
+	if cfg.APIKeys != "" {
+		keys, err := auth.LoadAPIKeys(cfg.APIKeys)
+		if err != nil {
+			fatal(logger, "error loading API keys", err)
+		}
+		authenticators = append(authenticators, keys)
+	}
+	if cfg.JWKS != "" {
+		jwt, err := auth.LoadJWT(cfg.JWKS, auth.JWTConfig{
+			Audience: cfg.JWTAudience,
+			Issuer:   cfg.JWTIssuer,
+			Leeway:   time.Duration(cfg.JWTLeeway),
+		})
+		if err != nil {
+			fatal(logger, "error loading JWKS", err)
+		}
+		authenticators = append(authenticators, jwt)
+	}
+	if len(authenticators) > 0 {
+		opts = append(opts, controllers.WithAuthorization())
+	} else {
+		if cfg.Tenants != "" {
+			logger.Warn("tenants are chosen by the X-Tenant-ID header of unauthenticated callers")
+		}
+		if cfg.InsecureAdmin {
+			logger.Warn("administrative routes are open to every caller")
+			opts = append(opts, controllers.WithInsecureAdmin())
+		}
+	}
+	c := controllers.New(m, opts...)
 
 	r := mux.NewRouter()
 	c.Register(r)
+	r.Use(
+		middleware.Recover,
+		middleware.Timeout(time.Duration(cfg.RequestTimeout)),
+		middleware.Gzip,
+	)
+	if len(authenticators) > 0 {
+		r.Use(auth.Middleware(authenticators...))
+	}
+	if cfg.RateLimits != "" {
+		rateLimits, err := ratelimit.Load(cfg.RateLimits)
+		if err != nil {
+			fatal(logger, "error loading rate limits", err)
+		}
+		r.Use(ratelimit.New(rateLimits).Middleware)
+	}
 
-	var port string
-	flag.StringVar(&port, "port", "8000", "localhost port")
-	flag.Parse()
-	addr := fmt.Sprintf("127.0.0.1:%s", port)
+	// Operational endpoints are served outside the router so they are not
+	// subject to authentication or rate limits.
+	root := http.NewServeMux()
+	root.Handle("/metrics", serviceMetrics.Handler())
+	root.Handle("/healthz", checker.Liveness())
+	root.Handle("/readyz", checker.Readiness())
+	var api http.Handler = r
+	if len(cfg.CORSOrigins) > 0 {
+		api = middleware.DefaultCORS(cfg.CORSOrigins).Handler(api)
+	}
+	// Logging, tracing and metrics wrap the router rather than being added
+	// to it, so requests matching no route are logged and counted too.
+	api = serviceMetrics.Middleware(api)
+	api = tracing.Middleware(api)
+	api = logging.Middleware(logger)(api)
+	api = logging.Routes(r)(api)
+	root.Handle("/", api)
 
 	srv := &http.Server{
-		Handler: r,
-		Addr:    addr,
+		Handler:      root,
+		Addr:         cfg.Addr,
+		ReadTimeout:  time.Duration(cfg.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.IdleTimeout),
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 	}
 
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	listen := srv.ListenAndServe
+	if cfg.TLSCert != "" {
+		reloader, err := tlsreload.New(tlsreload.Files{
+			Cert:              cfg.TLSCert,
+			Key:               cfg.TLSKey,
+			ClientCA:          cfg.TLSClientCA,
+			RequireClientCert: cfg.TLSRequireClientCert,
+		}, logger)
+		if err != nil {
+			fatal(logger, "error loading TLS certificates", err)
+		}
+		go reloader.Watch(backgroundCtx, tlsreload.DefaultWatchInterval)
+		srv.TLSConfig = reloader.Config()
+		listen = func() error { return srv.ListenAndServeTLS("", "") }
+	}
+	logger.Info("server listening", "addr", cfg.Addr, "tls", cfg.TLSCert != "")
+
 	go func() {
-		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("HTTP server error: %v", err)
+		if err := listen(); !errors.Is(err, http.ErrServerClosed) {
+			fatal(logger, "HTTP server error", err)
 		}
-		log.Println("Server shutting down...")
+		logger.Info("server shutting down")
 	}()
 
+	if policy.Enabled() {
+		for _, store := range stores {
+			go expiration.NewSweeper(store, policy).Run(backgroundCtx, time.Duration(cfg.ExpirySweepInterval))
+		}
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
+	stopBackground()
+
+	// Report not ready first so load balancers stop routing new requests
+	// here while in-flight ones finish.
+	checker.Drain()
+	logger.Info("draining connections", "drain_delay", cfg.DrainDelay.String())
+	time.Sleep(time.Duration(cfg.DrainDelay))
 
-	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownGrace))
 	defer shutdownRelease()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		srv.Close()
-		log.Fatalf("Error shutting down server: %v", err)
+		fatal(logger, "error shutting down server", err)
 	}
-	log.Println("Server shutdown complete.")
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("error flushing traces", "error", err.Error())
+	}
+	logger.Info("server shutdown complete")
+}
+
+// newStore opens an empty repository on the storage backend.
+func newStore(storage string) (repositories.Repository, error) {
+	switch storage {
+	case config.StorageMemory:
+		return repositories.New(), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", storage)
+}
+
+// fatal logs err and exits.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err.Error())
+	os.Exit(1)
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	_ "time/tzdata"
+
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/tiers"
+)
+
+func main() {
+	var format, rulesPath, tierName string
+	flag.StringVar(&format, "format", formatTable, "output format: table, json or csv")
+	flag.StringVar(&rulesPath, "rules", "", "path to a JSON ruleset file")
+	flag.StringVar(&tierName, "tier", "", "score for a member of this tier (Bronze, Silver or Gold) instead of at base points")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: score [-format table|json|csv] [-rules file] [-tier name] [file ...]\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Scores JSON or NDJSON receipts read from files, or stdin when no files are given.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	writer, ok := writers[format]
+	if !ok {
+		log.Printf("unknown output format %q", format)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ruleset := process.DefaultRuleset()
+	if rulesPath != "" {
+		var err error
+		ruleset, err = process.LoadRuleset(rulesPath)
+		if err != nil {
+			log.Fatalf("error loading ruleset: %v", err)
+		}
+	}
+
+	var tier *tiers.Tier
+	if tierName != "" {
+		named, ok := tiers.Default().Named(tierName)
+		if !ok {
+			log.Printf("unknown tier %q", tierName)
+			flag.Usage()
+			os.Exit(2)
+		}
+		tier = &named
+	}
+
+	var results []result
+	if flag.NArg() == 0 {
+		res, err := scoreReader(ruleset, tier, "stdin", os.Stdin)
+		if err != nil {
+			log.Fatalf("error reading stdin: %v", err)
+		}
+		results = append(results, res...)
+	}
+	for _, path := range flag.Args() {
+		res, err := scoreFile(ruleset, tier, path)
+		if err != nil {
+			log.Fatalf("error reading %s: %v", path, err)
+		}
+		results = append(results, res...)
+	}
+
+	if err := writer(os.Stdout, results); err != nil {
+		log.Fatalf("error writing results: %v", err)
+	}
+
+	for _, res := range results {
+		if res.Error != "" {
+			os.Exit(1)
+		}
+	}
+}
+
+func scoreFile(ruleset *process.Ruleset, tier *tiers.Tier, path string) ([]result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return scoreReader(ruleset, tier, path, f)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/tiers"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+
+	errMsgInvalidReceipt = "The receipt is invalid."
+)
+
+type result struct {
+	Source   string `json:"source"`
+	Index    int    `json:"index"`
+	Retailer string `json:"retailer,omitempty"`
+	Total    string `json:"total,omitempty"`
+	Points   int    `json:"points"`
+	Error    string `json:"error,omitempty"`
+}
+
+var writers = map[string]func(io.Writer, []result) error{
+	formatTable: writeTable,
+	formatJSON:  writeJSON,
+	formatCSV:   writeCSV,
+}
+
+// scoreReader decodes every receipt in r and scores it. A single JSON
+// object, a JSON array of receipts and newline delimited JSON are all
+// accepted. Receipts are scored for a member of tier, or at base points if
+// it is nil. Receipts that fail to decode, validate or score are reported
+// in the result rather than returned as an error.
+func scoreReader(ruleset *process.Ruleset, tier *tiers.Tier, source string, r io.Reader) ([]result, error) {
+	var results []result
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return results, nil
+		}
+		if err != nil {
+			return results, err
+		}
+
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(trimmed, &batch); err != nil {
+				return results, err
+			}
+			for _, item := range batch {
+				results = append(results, scoreRaw(ruleset, tier, source, len(results), item))
+			}
+			continue
+		}
+		results = append(results, scoreRaw(ruleset, tier, source, len(results), raw))
+	}
+}
+
+func scoreRaw(ruleset *process.Ruleset, tier *tiers.Tier, source string, index int, raw json.RawMessage) result {
+	res := result{Source: source, Index: index}
+
+	var receipt entities.Receipt
+	if err := json.Unmarshal(raw, &receipt); err != nil {
+		res.Error = fmt.Sprintf("could not unmarshal receipt: %s", err.Error())
+		return res
+	}
+	res.Retailer = receipt.Retailer
+	res.Total = receipt.Total
+
+	if !receipt.Validate() {
+		res.Error = errMsgInvalidReceipt
+		return res
+	}
+
+	score, processErrors := ruleset.ScoreForTier(context.Background(), receipt, tier)
+	if len(processErrors) != 0 {
+		res.Error = fmt.Sprintf("error calculating point total: %v", processErrors)
+		return res
+	}
+	res.Points = score.Total
+	return res
+}
+
+func writeTable(w io.Writer, results []result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tINDEX\tRETAILER\tTOTAL\tPOINTS\tERROR")
+	for _, res := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%s\n", res.Source, res.Index, res.Retailer, res.Total, res.Points, res.Error)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, results []result) error {
+	if results == nil {
+		results = []result{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func writeCSV(w io.Writer, results []result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"source", "index", "retailer", "total", "points", "error"})
+	for _, res := range results {
+		cw.Write([]string{
+			res.Source,
+			strconv.Itoa(res.Index),
+			res.Retailer,
+			res.Total,
+			strconv.Itoa(res.Points),
+			res.Error,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
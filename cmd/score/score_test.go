@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/tiers"
+)
+
+const (
+	targetReceipt      = `{"retailer":"Target","purchaseDate":"2022-01-01","purchaseTime":"13:01","items":[{"shortDescription":"Mountain Dew 12PK","price":"6.49"},{"shortDescription":"Emils Cheese Pizza","price":"12.25"},{"shortDescription":"Knorr Creamy Chicken","price":"1.26"},{"shortDescription":"Doritos Nacho Cheese","price":"3.35"},{"shortDescription":"   Klarbrunn 12-PK 12 FL OZ  ","price":"12.00"}],"total":"35.35"}`
+	mMarketReceipt     = `{"retailer":"M&M Corner Market","purchaseDate":"2022-03-20","purchaseTime":"14:33","items":[{"shortDescription":"Gatorade","price":"2.25"},{"shortDescription":"Gatorade","price":"2.25"},{"shortDescription":"Gatorade","price":"2.25"},{"shortDescription":"Gatorade","price":"2.25"}],"total":"9.00"}`
+	noRetailerReceipt  = `{"retailer":"","purchaseDate":"2022-03-20","purchaseTime":"14:33","items":[{"shortDescription":"Gatorade","price":"2.25"}],"total":"2.25"}`
+	badPurchaseReceipt = `{"retailer":"Target","purchaseDate":"not-a-date","purchaseTime":"14:33","items":[{"shortDescription":"Gatorade","price":"2.25"}],"total":"2.25"}`
+)
+
+func Test_scoreReader(t *testing.T) {
+	testCases := map[string]struct {
+		input          string
+		tier           string
+		expectedPoints []int
+		expectedErrors []bool
+		expectError    bool
+	}{
+		"single receipt": {
+			input:          targetReceipt,
+			expectedPoints: []int{28},
+			expectedErrors: []bool{false},
+		},
+		"ndjson receipts": {
+			input:          targetReceipt + "\n" + mMarketReceipt + "\n",
+			expectedPoints: []int{28, 109},
+			expectedErrors: []bool{false, false},
+		},
+		"array of receipts": {
+			input:          "[" + targetReceipt + "," + mMarketReceipt + "]",
+			expectedPoints: []int{28, 109},
+			expectedErrors: []bool{false, false},
+		},
+		"invalid receipts are reported": {
+			input:          noRetailerReceipt + "\n" + badPurchaseReceipt + "\n" + targetReceipt,
+			expectedPoints: []int{0, 0, 28},
+			expectedErrors: []bool{true, true, false},
+		},
+		"scored for a tier": {
+			input:          targetReceipt + "\n" + mMarketReceipt + "\n",
+			tier:           "gold",
+			expectedPoints: []int{42, 163},
+			expectedErrors: []bool{false, false},
+		},
+		"malformed json": {
+			input:       targetReceipt + "\n{",
+			expectError: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			var tier *tiers.Tier
+			if tc.tier != "" {
+				named, ok := tiers.Default().Named(tc.tier)
+				if !ok {
+					t.Fatalf("unknown tier %q", tc.tier)
+				}
+				tier = &named
+			}
+			results, err := scoreReader(process.DefaultRuleset(), tier, "test", strings.NewReader(tc.input))
+			if tc.expectError {
+				if err == nil {
+					t.Error("expected error but did not get one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if len(results) != len(tc.expectedPoints) {
+				t.Fatalf("unexpected result count: got %d, want %d", len(results), len(tc.expectedPoints))
+			}
+			for i, res := range results {
+				if res.Points != tc.expectedPoints[i] {
+					t.Errorf("result %d: unexpected points: got %d, want %d", i, res.Points, tc.expectedPoints[i])
+				}
+				if (res.Error != "") != tc.expectedErrors[i] {
+					t.Errorf("result %d: unexpected error %q", i, res.Error)
+				}
+			}
+		})
+	}
+}
+
+func Test_writers(t *testing.T) {
+	results := []result{{Source: "test", Retailer: "Target", Total: "35.35", Points: 28}}
+
+	testCases := map[string]struct {
+		format   string
+		expected string
+	}{
+		"table": {
+			format:   formatTable,
+			expected: "Target    35.35  28",
+		},
+		"json": {
+			format:   formatJSON,
+			expected: `"points": 28`,
+		},
+		"csv": {
+			format:   formatCSV,
+			expected: "test,0,Target,35.35,28,",
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writers[tc.format](&buf, results); err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if !strings.Contains(buf.String(), tc.expected) {
+				t.Errorf("output %q does not contain %q", buf.String(), tc.expected)
+			}
+		})
+	}
+}
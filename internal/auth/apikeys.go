@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// HeaderAPIKey carries the client's API key.
+	HeaderAPIKey = "X-API-Key"
+	// HeaderTenantID names the loyalty program a request is for when the
+	// caller's credentials are not tied to one.
+	HeaderTenantID = "X-Tenant-ID"
+
+	hashPrefix = "sha256:"
+)
+
+// APIKey is a client's hashed key as stored in the key file.
+type APIKey struct {
+	ClientID string `json:"clientId"`
+	Name     string `json:"name,omitempty"`
+	// Hash is "sha256:" followed by the hex SHA-256 digest of the key.
+	Hash string `json:"hash"`
+	// Scopes are granted to every request made with the key.
+	Scopes []string `json:"scopes,omitempty"`
+	// Tenant restricts the key to one loyalty program.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// APIKeys authenticates requests by the X-API-Key header. Only key hashes
+// are held in memory.
+type APIKeys struct {
+	keys []APIKey
+}
+
+func NewAPIKeys(keys []APIKey) (*APIKeys, error) {
+	for i, key := range keys {
+		if key.ClientID == "" {
+			return nil, fmt.Errorf("api key %d has no client id", i)
+		}
+		digest, ok := strings.CutPrefix(key.Hash, hashPrefix)
+		if b, err := hex.DecodeString(digest); !ok || err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("api key for %s has an invalid hash", key.ClientID)
+		}
+	}
+	return &APIKeys{keys: keys}, nil
+}
+
+// LoadAPIKeys reads a JSON array of APIKey from path.
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("could not unmarshal api keys: %w", err)
+	}
+	return NewAPIKeys(keys)
+}
+
+func (a *APIKeys) Authenticate(r *http.Request) (Identity, error) {
+	key := r.Header.Get(HeaderAPIKey)
+	if key == "" {
+		return Identity{}, ErrNoCredentials
+	}
+
+	hash := HashKey(key)
+	var (
+		match Identity
+		found bool
+	)
+	// Compare against every key in constant time so the response time does
+	// not reveal which keys exist.
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(k.Hash)) == 1 {
+			match = Identity{ClientID: k.ClientID, Name: k.Name, Scopes: k.Scopes, Tenant: k.Tenant}
+			found = true
+		}
+	}
+	if !found {
+		return Identity{}, ErrInvalidCredentials
+	}
+	return match, nil
+}
+
+// HashKey returns the stored form of key.
+func HashKey(key string) string {
+	digest := sha256.Sum256([]byte(key))
+	return hashPrefix + hex.EncodeToString(digest[:])
+}
+
+// GenerateKey returns a new random API key.
+func GenerateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
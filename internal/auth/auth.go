@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request does
+	// not carry the kind of credentials it handles.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when credentials are present but
+	// are not accepted.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+const errMsgUnauthorized = "Authentication is required."
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	// ClientID names the integration that sent the request.
+	ClientID string
+	Name     string
+	// Subject is the end user a bearer token was issued to. Requests with
+	// a subject act on behalf of that user.
+	Subject string
+	// Scopes are the operations the caller is allowed beyond ordinary
+	// receipt processing.
+	Scopes []string
+	// Tenant is the loyalty program the caller belongs to, if its
+	// credentials are tied to one.
+	Tenant string
+}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+type contextKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity attached by Middleware.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
+
+// Middleware rejects requests that no authenticator accepts with 401
+// Unauthorized, and attaches the identity of accepted requests to their
+// context. Authenticators are tried in order until one finds credentials.
+func Middleware(authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, a := range authenticators {
+				id, err := a.Authenticate(r)
+				if errors.Is(err, ErrNoCredentials) {
+					continue
+				}
+				if err != nil {
+					logging.FromContext(r.Context(), slog.Default()).Warn("rejected credentials",
+						"remote_addr", r.RemoteAddr, "error", err.Error())
+					break
+				}
+				next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+				return
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(errMsgUnauthorized))
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_APIKeyMiddleware(t *testing.T) {
+	keys, err := LoadAPIKeys("testdata/api-keys.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotIdentity Identity
+	handler := Middleware(keys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIdentity, _ = FromContext(r.Context())
+	}))
+
+	testCases := map[string]struct {
+		key              string
+		expStatusCode    int
+		expectedClientID string
+	}{
+		"valid key": {
+			key:              "partner-a-secret",
+			expStatusCode:    http.StatusOK,
+			expectedClientID: "partner-a",
+		},
+		"second client": {
+			key:              "partner-b-secret",
+			expStatusCode:    http.StatusOK,
+			expectedClientID: "partner-b",
+		},
+		"unknown key": {
+			key:           "guess",
+			expStatusCode: http.StatusUnauthorized,
+		},
+		"missing key": {
+			expStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			gotIdentity = Identity{}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.key != "" {
+				req.Header.Set(HeaderAPIKey, tc.key)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expStatusCode {
+				t.Errorf("unexpected status code: got %d, want %d", rec.Code, tc.expStatusCode)
+			}
+			if gotIdentity.ClientID != tc.expectedClientID {
+				t.Errorf("unexpected client id: got %q, want %q", gotIdentity.ClientID, tc.expectedClientID)
+			}
+		})
+	}
+}
+
+func Test_NewAPIKeys(t *testing.T) {
+	testCases := map[string]struct {
+		input       []APIKey
+		expectError bool
+	}{
+		"valid": {
+			input: []APIKey{{ClientID: "a", Hash: HashKey("key")}},
+		},
+		"missing client id": {
+			input:       []APIKey{{Hash: HashKey("key")}},
+			expectError: true,
+		},
+		"plaintext key": {
+			input:       []APIKey{{ClientID: "a", Hash: "key"}},
+			expectError: true,
+		},
+		"short digest": {
+			input:       []APIKey{{ClientID: "a", Hash: "sha256:abcd"}},
+			expectError: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			_, err := NewAPIKeys(tc.input)
+			if tc.expectError && err == nil {
+				t.Error("expected error but did not get one")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		})
+	}
+}
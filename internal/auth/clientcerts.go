@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ClientCert maps a client certificate subject to a client.
+type ClientCert struct {
+	// Subject is the certificate's distinguished name as Go prints it,
+	// such as "CN=partner-a,O=Acme Corp".
+	Subject  string `json:"subject"`
+	ClientID string `json:"clientId"`
+	Name     string `json:"name,omitempty"`
+	// Scopes are granted to every request made with the certificate.
+	Scopes []string `json:"scopes,omitempty"`
+	// Tenant restricts the certificate to one loyalty program.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// ClientCerts authenticates requests by the client certificate verified
+// during the TLS handshake. Without a mapping, the certificate's common
+// name is the client ID.
+type ClientCerts struct {
+	bySubject map[string]ClientCert
+}
+
+// NewClientCerts accepts only the listed subjects, or any verified
+// certificate with a common name if certs is empty.
+func NewClientCerts(certs []ClientCert) (*ClientCerts, error) {
+	a := &ClientCerts{}
+	for i, cert := range certs {
+		if cert.Subject == "" || cert.ClientID == "" {
+			return nil, fmt.Errorf("client cert %d needs a subject and client id", i)
+		}
+		if a.bySubject == nil {
+			a.bySubject = make(map[string]ClientCert, len(certs))
+		}
+		if _, ok := a.bySubject[cert.Subject]; ok {
+			return nil, fmt.Errorf("duplicate client cert subject %q", cert.Subject)
+		}
+		a.bySubject[cert.Subject] = cert
+	}
+	return a, nil
+}
+
+// LoadClientCerts reads a JSON array of ClientCert from path.
+func LoadClientCerts(path string) (*ClientCerts, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []ClientCert
+	if err := json.Unmarshal(b, &certs); err != nil {
+		return nil, fmt.Errorf("could not unmarshal client certs: %w", err)
+	}
+	return NewClientCerts(certs)
+}
+
+func (a *ClientCerts) Authenticate(r *http.Request) (Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, ErrNoCredentials
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	subject := leaf.Subject.String()
+
+	if a.bySubject == nil {
+		if leaf.Subject.CommonName == "" {
+			return Identity{}, fmt.Errorf("%w: client certificate has no common name", ErrInvalidCredentials)
+		}
+		return Identity{ClientID: leaf.Subject.CommonName, Name: subject}, nil
+	}
+
+	cert, ok := a.bySubject[subject]
+	if !ok {
+		return Identity{}, fmt.Errorf("%w: unknown client certificate subject %q", ErrInvalidCredentials, subject)
+	}
+	return Identity{ClientID: cert.ClientID, Name: cert.Name, Scopes: cert.Scopes, Tenant: cert.Tenant}, nil
+}
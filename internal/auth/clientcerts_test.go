@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ClientCerts(t *testing.T) {
+	mapped, err := LoadClientCerts("testdata/client-certs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmapped, err := NewClientCerts(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partnerA := pkix.Name{CommonName: "partner-a", Organization: []string{"Acme Corp"}}
+	testCases := map[string]struct {
+		authenticator *ClientCerts
+		tls           bool
+		subject       *pkix.Name
+		expectedErr   error
+		expected      Identity
+	}{
+		"plain http": {
+			authenticator: mapped,
+			expectedErr:   ErrNoCredentials,
+		},
+		"no client certificate": {
+			authenticator: mapped,
+			tls:           true,
+			expectedErr:   ErrNoCredentials,
+		},
+		"mapped subject": {
+			authenticator: mapped,
+			tls:           true,
+			subject:       &partnerA,
+			expected:      Identity{ClientID: "partner-a", Name: "Acme", Scopes: []string{ScopeReceiptsReview}},
+		},
+		"mapped subject with tenant": {
+			authenticator: mapped,
+			tls:           true,
+			subject:       &pkix.Name{CommonName: "brunch-gateway"},
+			expected:      Identity{ClientID: "brunch", Tenant: "brunch-club"},
+		},
+		"unknown subject": {
+			authenticator: mapped,
+			tls:           true,
+			subject:       &pkix.Name{CommonName: "partner-a"},
+			expectedErr:   ErrInvalidCredentials,
+		},
+		"common name without mapping": {
+			authenticator: unmapped,
+			tls:           true,
+			subject:       &partnerA,
+			expected:      Identity{ClientID: "partner-a", Name: "CN=partner-a,O=Acme Corp"},
+		},
+		"no common name without mapping": {
+			authenticator: unmapped,
+			tls:           true,
+			subject:       &pkix.Name{Organization: []string{"Acme Corp"}},
+			expectedErr:   ErrInvalidCredentials,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/receipts", nil)
+			req.TLS = nil
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+				if tc.subject != nil {
+					leaf := &x509.Certificate{Subject: *tc.subject}
+					req.TLS.VerifiedChains = [][]*x509.Certificate{{leaf}}
+				}
+			}
+
+			id, err := tc.authenticator.Authenticate(req)
+			if tc.expectedErr != nil {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Errorf("unexpected error: got %v, expected %v", err, tc.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if id.ClientID != tc.expected.ClientID || id.Name != tc.expected.Name || id.Tenant != tc.expected.Tenant ||
+				len(id.Scopes) != len(tc.expected.Scopes) {
+				t.Errorf("unexpected identity: got %+v, expected %+v", id, tc.expected)
+			}
+		})
+	}
+}
+
+func Test_NewClientCerts(t *testing.T) {
+	testCases := map[string][]ClientCert{
+		"missing subject":   {{ClientID: "partner-a"}},
+		"missing client id": {{Subject: "CN=partner-a"}},
+		"duplicate subject": {{Subject: "CN=partner-a", ClientID: "a"}, {Subject: "CN=partner-a", ClientID: "b"}},
+	}
+	for caseName, certs := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			if _, err := NewClientCerts(certs); err == nil {
+				t.Error("expected error but did not get one")
+			}
+		})
+	}
+}
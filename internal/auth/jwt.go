@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgHS256 = "HS256"
+
+	headerAuthorization = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+var (
+	errMalformedToken = errors.New("malformed token")
+	errNoMatchingKey  = errors.New("no key matches the token")
+	errBadSignature   = errors.New("signature verification failed")
+)
+
+// JWK is a JSON Web Key as it appears in a key set file.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	// RSA public keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC public keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	// Symmetric keys.
+	K string `json:"k,omitempty"`
+}
+
+type verificationKey struct {
+	kid string
+	alg string
+	key interface{}
+}
+
+// JWTConfig sets the claims a token must carry.
+type JWTConfig struct {
+	// Audience must appear in the token's aud claim.
+	Audience string
+	// Issuer, when set, must equal the token's iss claim.
+	Issuer string
+	// Leeway allows for clock skew when checking exp and nbf.
+	Leeway time.Duration
+}
+
+// JWT authenticates requests with a bearer token signed by a key in a local
+// JWKS file. The token's sub claim becomes the identity's Subject.
+type JWT struct {
+	keys   []verificationKey
+	config JWTConfig
+	now    func() time.Time
+}
+
+// LoadJWT reads the key set at path.
+func LoadJWT(path string, config JWTConfig) (*JWT, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("could not unmarshal key set: %w", err)
+	}
+	return NewJWT(set.Keys, config)
+}
+
+func NewJWT(jwks []JWK, config JWTConfig) (*JWT, error) {
+	if config.Audience == "" {
+		return nil, errors.New("jwt audience is required")
+	}
+	j := &JWT{config: config, now: time.Now}
+	for i, jwk := range jwks {
+		key, err := parseJWK(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("key %d %q: %w", i, jwk.Kid, err)
+		}
+		j.keys = append(j.keys, key)
+	}
+	if len(j.keys) == 0 {
+		return nil, errors.New("key set has no keys")
+	}
+	return j, nil
+}
+
+func parseJWK(jwk JWK) (verificationKey, error) {
+	key := verificationKey{kid: jwk.Kid}
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return key, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return key, err
+		}
+		key.alg = AlgRS256
+		key.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return key, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return key, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return key, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !pub.Curve.IsOnCurve(x, y) {
+			return key, errors.New("point is not on the curve")
+		}
+		key.alg = AlgES256
+		key.key = pub
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil || len(secret) == 0 {
+			return key, errors.New("invalid symmetric key")
+		}
+		key.alg = AlgHS256
+		key.key = secret
+	default:
+		return key, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+	if jwk.Alg != "" && jwk.Alg != key.alg {
+		return key, fmt.Errorf("unsupported algorithm %q for key type %q", jwk.Alg, jwk.Kty)
+	}
+	return key, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+	ClientID  string   `json:"client_id"`
+	Azp       string   `json:"azp"`
+	Scope     scopes   `json:"scope"`
+	Scp       scopes   `json:"scp"`
+	Roles     scopes   `json:"roles"`
+	Tenant    string   `json:"tenant"`
+}
+
+// audience accepts the aud claim as a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// scopes accepts a space separated string or an array of strings, as the
+// scope, scp and roles claims are issued in either form.
+type scopes []string
+
+func (s *scopes) UnmarshalJSON(b []byte) error {
+	var joined string
+	if err := json.Unmarshal(b, &joined); err == nil {
+		*s = strings.Fields(joined)
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*s = many
+	return nil
+}
+
+func (j *JWT) Authenticate(r *http.Request) (Identity, error) {
+	header := r.Header.Get(headerAuthorization)
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return Identity{}, ErrNoCredentials
+	}
+	claims, err := j.verify(strings.TrimPrefix(header, bearerPrefix))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	id := Identity{Subject: claims.Subject, ClientID: claims.ClientID, Tenant: claims.Tenant}
+	id.Scopes = append(id.Scopes, claims.Scope...)
+	id.Scopes = append(id.Scopes, claims.Scp...)
+	id.Scopes = append(id.Scopes, claims.Roles...)
+	if id.ClientID == "" {
+		id.ClientID = claims.Azp
+	}
+	return id, nil
+}
+
+func (j *JWT) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errMalformedToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	var matched bool
+	for _, key := range j.keys {
+		if key.alg != header.Alg || (header.Kid != "" && key.kid != header.Kid) {
+			continue
+		}
+		matched = true
+		if verifySignature(key, signed, signature) {
+			return j.checkClaims(parts[1])
+		}
+	}
+	if !matched {
+		return nil, errNoMatchingKey
+	}
+	return nil, errBadSignature
+}
+
+func verifySignature(key verificationKey, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch k := key.key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, digest[:], r, s)
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	return false
+}
+
+func (j *JWT) checkClaims(payload string) (*jwtClaims, error) {
+	var claims jwtClaims
+	if err := decodeSegment(payload, &claims); err != nil {
+		return nil, err
+	}
+
+	now := j.now()
+	switch {
+	case claims.ExpiresAt == nil:
+		return nil, errors.New("token has no expiry")
+	case now.After(time.Unix(*claims.ExpiresAt, 0).Add(j.config.Leeway)):
+		return nil, errors.New("token has expired")
+	case claims.NotBefore != nil && now.Add(j.config.Leeway).Before(time.Unix(*claims.NotBefore, 0)):
+		return nil, errors.New("token is not valid yet")
+	case j.config.Issuer != "" && claims.Issuer != j.config.Issuer:
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case claims.Subject == "":
+		return nil, errors.New("token has no subject")
+	}
+	for _, aud := range claims.Audience {
+		if aud == j.config.Audience {
+			return &claims, nil
+		}
+	}
+	return nil, errors.New("token is not for this audience")
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errMalformedToken
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errMalformedToken
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testAudience = "receipt-processor"
+
+var (
+	testRSAKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testSecret    = []byte("0123456789abcdef0123456789abcdef")
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func testJWKS() []JWK {
+	return []JWK{
+		{
+			Kty: "RSA",
+			Kid: "rsa-1",
+			N:   b64(testRSAKey.N.Bytes()),
+			E:   b64(big.NewInt(int64(testRSAKey.E)).Bytes()),
+		},
+		{
+			Kty: "EC",
+			Kid: "ec-1",
+			Crv: "P-256",
+			X:   b64(testECKey.X.FillBytes(make([]byte, 32))),
+			Y:   b64(testECKey.Y.FillBytes(make([]byte, 32))),
+		},
+		{
+			Kty: "oct",
+			Kid: "hmac-1",
+			K:   b64(testSecret),
+		},
+	}
+}
+
+// signToken builds a token with the given header and claims signed by key.
+func signToken(t *testing.T, alg, kid string, claims map[string]interface{}, key interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	return signed + "." + b64(signature)
+}
+
+func Test_JWTAuthenticate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	jwt, err := NewJWT(testJWKS(), JWTConfig{Audience: testAudience, Issuer: "https://issuer.example", Leeway: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwt.now = func() time.Time { return now }
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "user-123",
+			"aud": testAudience,
+			"iss": "https://issuer.example",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+	otherRSAKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	testCases := map[string]struct {
+		header          string
+		expectedSubject string
+		expectedClient  string
+		expectedScope   string
+		expectedErr     error
+	}{
+		"RS256": {
+			header:          "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(nil), testRSAKey),
+			expectedSubject: "user-123",
+		},
+		"ES256": {
+			header:          "Bearer " + signToken(t, AlgES256, "ec-1", claims(nil), testECKey),
+			expectedSubject: "user-123",
+		},
+		"HS256 without kid": {
+			header:          "Bearer " + signToken(t, AlgHS256, "", claims(nil), testSecret),
+			expectedSubject: "user-123",
+		},
+		"audience list and client": {
+			header:          "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(map[string]interface{}{"aud": []string{"other", testAudience}, "azp": "mobile-app"}), testRSAKey),
+			expectedSubject: "user-123",
+			expectedClient:  "mobile-app",
+		},
+		"expired within leeway": {
+			header:          "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}), testRSAKey),
+			expectedSubject: "user-123",
+		},
+		"scope claim": {
+			header:          "Bearer " + signToken(t, AlgES256, "ec-1", claims(map[string]interface{}{"scope": "openid " + ScopeReceiptsReview}), testECKey),
+			expectedSubject: "user-123",
+			expectedScope:   ScopeReceiptsReview,
+		},
+		"roles claim": {
+			header:          "Bearer " + signToken(t, AlgES256, "ec-1", claims(map[string]interface{}{"roles": []string{ScopeAdmin}}), testECKey),
+			expectedSubject: "user-123",
+			expectedScope:   ScopeRewardsWrite,
+		},
+		"no bearer token": {
+			expectedErr: ErrNoCredentials,
+		},
+		"expired": {
+			header:      "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()}), testRSAKey),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"no expiry": {
+			header:      "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(map[string]interface{}{"exp": nil}), testRSAKey),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"not valid yet": {
+			header:      "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()}), testRSAKey),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"wrong audience": {
+			header:      "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(map[string]interface{}{"aud": "other"}), testRSAKey),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"wrong issuer": {
+			header:      "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(map[string]interface{}{"iss": "https://evil.example"}), testRSAKey),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"no subject": {
+			header:      "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(map[string]interface{}{"sub": nil}), testRSAKey),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"signed by unknown key": {
+			header:      "Bearer " + signToken(t, AlgRS256, "rsa-1", claims(nil), otherRSAKey),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"unknown kid": {
+			header:      "Bearer " + signToken(t, AlgRS256, "rsa-2", claims(nil), testRSAKey),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"algorithm does not match key": {
+			header:      "Bearer " + signToken(t, AlgHS256, "rsa-1", claims(nil), testSecret),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"unsigned token": {
+			header:      "Bearer " + signToken(t, "none", "", claims(nil), nil),
+			expectedErr: ErrInvalidCredentials,
+		},
+		"malformed token": {
+			header:      "Bearer not-a-token",
+			expectedErr: ErrInvalidCredentials,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				r.Header.Set(headerAuthorization, tc.header)
+			}
+			id, err := jwt.Authenticate(r)
+			if tc.expectedErr != nil {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Errorf("unexpected error: got %v, want %v", err, tc.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if id.Subject != tc.expectedSubject {
+				t.Errorf("unexpected subject: got %q, want %q", id.Subject, tc.expectedSubject)
+			}
+			if id.ClientID != tc.expectedClient {
+				t.Errorf("unexpected client id: got %q, want %q", id.ClientID, tc.expectedClient)
+			}
+			if tc.expectedScope != "" && !id.HasScope(tc.expectedScope) {
+				t.Errorf("expected scope %q in %v", tc.expectedScope, id.Scopes)
+			}
+		})
+	}
+}
+
+func Test_LoadJWT(t *testing.T) {
+	b, err := json.Marshal(map[string][]JWK{"keys": testJWKS()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadJWT(path, JWTConfig{Audience: testAudience}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if _, err := LoadJWT(path, JWTConfig{}); err == nil {
+		t.Error("expected error without an audience but did not get one")
+	}
+	if _, err := NewJWT([]JWK{{Kty: "RSA", Alg: AlgHS256, N: "AQAB", E: "AQAB"}}, JWTConfig{Audience: testAudience}); err == nil {
+		t.Error("expected error for mismatched algorithm but did not get one")
+	}
+	if _, err := NewJWT([]JWK{{Kty: "EC", Crv: "P-384", X: "AQAB", Y: "AQAB"}}, JWTConfig{Audience: testAudience}); err == nil {
+		t.Error("expected error for unsupported curve but did not get one")
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+)
+
+// Scopes grant access to operations ordinary clients may not perform.
+// ScopeAdmin grants every other scope.
+const (
+	ScopeAdmin          = "admin"
+	ScopeReceiptsReview = "receipts:review"
+	ScopeReceiptsVoid   = "receipts:void"
+	ScopeRewardsWrite   = "rewards:write"
+	// ScopeAnyTenant lets credentials not tied to a tenant act for the
+	// one named by HeaderTenantID.
+	ScopeAnyTenant = "tenants:any"
+)
+
+const errMsgForbidden = "You do not have permission to do that."
+
+// HasScope reports whether the identity was granted scope, either directly
+// or through ScopeAdmin.
+func (id Identity) HasScope(scope string) bool {
+	for _, s := range id.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Require rejects requests whose identity lacks scope with 403 Forbidden,
+// and requests without an identity with 401 Unauthorized. Denied attempts
+// are written to the audit log. It must run after Middleware.
+func Require(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := logging.FromContext(r.Context(), slog.Default()).With(
+				"audit", true, "path", r.URL.Path, "remote_addr", r.RemoteAddr, "required_scope", scope)
+			id, ok := FromContext(r.Context())
+			if !ok {
+				logger.Warn("access denied: not authenticated")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(errMsgUnauthorized))
+				return
+			}
+			if !id.HasScope(scope) {
+				logger.Warn("access denied: missing scope", "client_id", id.ClientID, "subject", id.Subject)
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(errMsgForbidden))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Require(t *testing.T) {
+	keys, err := NewAPIKeys([]APIKey{
+		{ClientID: "partner-a", Hash: HashKey("partner-a-secret")},
+		{ClientID: "reviewer", Hash: HashKey("reviewer-secret"), Scopes: []string{ScopeReceiptsReview}},
+		{ClientID: "ops", Hash: HashKey("ops-secret"), Scopes: []string{ScopeAdmin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	testCases := map[string]struct {
+		handler       http.Handler
+		key           string
+		expStatusCode int
+	}{
+		"scope granted": {
+			handler:       Middleware(keys)(Require(ScopeReceiptsReview)(ok)),
+			key:           "reviewer-secret",
+			expStatusCode: http.StatusOK,
+		},
+		"admin grants every scope": {
+			handler:       Middleware(keys)(Require(ScopeReceiptsReview)(ok)),
+			key:           "ops-secret",
+			expStatusCode: http.StatusOK,
+		},
+		"scope missing": {
+			handler:       Middleware(keys)(Require(ScopeReceiptsReview)(ok)),
+			key:           "partner-a-secret",
+			expStatusCode: http.StatusForbidden,
+		},
+		"other scope": {
+			handler:       Middleware(keys)(Require(ScopeReceiptsVoid)(ok)),
+			key:           "reviewer-secret",
+			expStatusCode: http.StatusForbidden,
+		},
+		"not authenticated": {
+			handler:       Require(ScopeReceiptsReview)(ok),
+			expStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/admin/receipts", nil)
+			if tc.key != "" {
+				r.Header.Set(HeaderAPIKey, tc.key)
+			}
+			w := httptest.NewRecorder()
+			tc.handler.ServeHTTP(w, r)
+			if w.Code != tc.expStatusCode {
+				t.Errorf("unexpected status code: got %d, want %d", w.Code, tc.expStatusCode)
+			}
+		})
+	}
+}
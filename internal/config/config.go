@@ -0,0 +1,598 @@
+// Package config assembles the server's settings from defaults, a JSON
+// config file, environment variables and command line flags, each
+// overriding the one before.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+	"github.com/gpayne44/fetch-challenge/internal/tracing"
+)
+
+const (
+	StorageMemory = "memory"
+
+	// EnvPrefix starts the name of every environment variable read.
+	EnvPrefix = "RECEIPTS_"
+	// EnvConfigFile names the config file when -config is not given.
+	EnvConfigFile = EnvPrefix + "CONFIG"
+)
+
+// Config holds the server settings.
+type Config struct {
+	// Addr is the host and port the server listens on.
+	Addr string `json:"addr"`
+	// ReadTimeout bounds reading a whole request, body included.
+	ReadTimeout Duration `json:"readTimeout"`
+	// WriteTimeout bounds handling a request and writing its response.
+	WriteTimeout Duration `json:"writeTimeout"`
+	// IdleTimeout bounds how long a keep-alive connection waits for its
+	// next request.
+	IdleTimeout Duration `json:"idleTimeout"`
+	// RequestTimeout is the deadline for handling each request, which
+	// scoring and storage give up at.
+	RequestTimeout Duration `json:"requestTimeout"`
+	// DrainDelay is how long the server reports not ready before it stops
+	// accepting connections.
+	DrainDelay Duration `json:"drainDelay"`
+	// ShutdownGrace is how long in-flight requests get to finish once the
+	// server stops accepting connections.
+	ShutdownGrace Duration `json:"shutdownGrace"`
+	// Storage is the repository backend. Only StorageMemory is available.
+	Storage string `json:"storage"`
+	// LogLevel is the lowest level logged: debug, info, warn or error.
+	LogLevel string `json:"logLevel"`
+	// Rules is a JSON ruleset file, or empty for the default ruleset.
+	Rules string `json:"rules,omitempty"`
+	// TLSCert and TLSKey are PEM files the server's certificate is loaded
+	// from. The server speaks plain HTTP when they are empty.
+	TLSCert string `json:"tlsCert,omitempty"`
+	TLSKey  string `json:"tlsKey,omitempty"`
+	// TLSClientCA is a PEM bundle of CAs that client certificates are
+	// verified against. Clients need not present one unless
+	// TLSRequireClientCert is set.
+	TLSClientCA          string `json:"tlsClientCA,omitempty"`
+	TLSRequireClientCert bool   `json:"tlsRequireClientCert,omitempty"`
+	// CORSOrigins are the browser origins, such as
+	// "https://app.example.com", allowed to call the API. "*" allows any.
+	CORSOrigins []string `json:"corsOrigins,omitempty"`
+	// InsecureAdmin opens the administrative routes to every caller when
+	// no authentication is configured. They are refused otherwise.
+	InsecureAdmin bool `json:"insecureAdmin,omitempty"`
+
+	// Tenants is a JSON file of tenants, each served from its own store.
+	Tenants string `json:"tenants,omitempty"`
+	// APIKeys is a JSON file of hashed API keys, which every request must
+	// carry one of when set.
+	APIKeys string `json:"apiKeys,omitempty"`
+	// ClientCerts is a JSON file mapping client certificate subjects to
+	// clients. It needs TLSClientCA.
+	ClientCerts string `json:"clientCerts,omitempty"`
+	// JWKS is a JSON Web Key Set file whose keys verify bearer tokens,
+	// which must be issued for JWTAudience and, when set, by JWTIssuer.
+	JWKS        string `json:"jwks,omitempty"`
+	JWTAudience string `json:"jwtAudience,omitempty"`
+	JWTIssuer   string `json:"jwtIssuer,omitempty"`
+	// JWTLeeway is the clock skew allowed when checking token expiry.
+	JWTLeeway Duration `json:"jwtLeeway"`
+	// RateLimits is a JSON file of per-route rate limits and daily quotas.
+	RateLimits string `json:"rateLimits,omitempty"`
+
+	// ExpireAfterMonths and ExpireInactiveMonths set when points expire,
+	// after they were earned and after the user was last active. 0
+	// disables each.
+	ExpireAfterMonths    int `json:"expireAfterMonths"`
+	ExpireInactiveMonths int `json:"expireInactiveMonths"`
+	// ExpirySweepInterval is how often expired points are posted.
+	ExpirySweepInterval Duration `json:"expirySweepInterval"`
+
+	// MaxBodyBytes is the largest request body accepted.
+	MaxBodyBytes int64 `json:"maxBodyBytes"`
+	// MaxItems is the most items a receipt may list, 0 for no limit.
+	MaxItems int `json:"maxItems"`
+	// MaxStringLength is the longest retailer or item description
+	// accepted in bytes, 0 for no limit.
+	MaxStringLength int `json:"maxStringLength"`
+	// StrictJSON rejects request bodies with unknown fields.
+	StrictJSON bool `json:"strictJSON,omitempty"`
+
+	// TraceExporter is where spans are sent: none, otlp, stdout or file.
+	TraceExporter string `json:"traceExporter"`
+	// TraceFile is where the file exporter appends spans.
+	TraceFile string `json:"traceFile,omitempty"`
+	// TraceSampleRatio is the fraction of new traces recorded.
+	TraceSampleRatio float64 `json:"traceSampleRatio"`
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() Config {
+	return Config{
+		Addr:           "127.0.0.1:8000",
+		ReadTimeout:    Duration(10 * time.Second),
+		WriteTimeout:   Duration(30 * time.Second),
+		IdleTimeout:    Duration(2 * time.Minute),
+		RequestTimeout: Duration(15 * time.Second),
+		DrainDelay:     Duration(5 * time.Second),
+		ShutdownGrace:  Duration(10 * time.Second),
+		Storage:        StorageMemory,
+		LogLevel:       "info",
+
+		JWTLeeway:           Duration(time.Minute),
+		ExpirySweepInterval: Duration(time.Hour),
+		MaxBodyBytes:        1 << 20,
+		MaxItems:            500,
+		MaxStringLength:     256,
+		TraceExporter:       tracing.ExporterNone,
+		TraceSampleRatio:    1,
+	}
+}
+
+func (cfg Config) Validate() error {
+	_, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("addr: %w", err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("addr: invalid port %q", port)
+	}
+
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{
+		{"readTimeout", cfg.ReadTimeout},
+		{"writeTimeout", cfg.WriteTimeout},
+		{"idleTimeout", cfg.IdleTimeout},
+		{"requestTimeout", cfg.RequestTimeout},
+		{"drainDelay", cfg.DrainDelay},
+	} {
+		if d.value < 0 {
+			return fmt.Errorf("%s cannot be negative", d.name)
+		}
+	}
+	if cfg.ShutdownGrace <= 0 {
+		return errors.New("shutdownGrace must be positive")
+	}
+
+	if cfg.Storage != StorageMemory {
+		return fmt.Errorf("unknown storage backend %q", cfg.Storage)
+	}
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		return fmt.Errorf("logLevel: %w", err)
+	}
+	for _, f := range []struct {
+		name string
+		path string
+	}{
+		{"rules", cfg.Rules},
+		{"tenants", cfg.Tenants},
+		{"apiKeys", cfg.APIKeys},
+		{"clientCerts", cfg.ClientCerts},
+		{"jwks", cfg.JWKS},
+		{"rateLimits", cfg.RateLimits},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+
+	switch {
+	case (cfg.TLSCert == "") != (cfg.TLSKey == ""):
+		return errors.New("tlsCert and tlsKey must be set together")
+	case cfg.TLSClientCA != "" && cfg.TLSCert == "":
+		return errors.New("tlsClientCA needs tlsCert and tlsKey")
+	case cfg.TLSRequireClientCert && cfg.TLSClientCA == "":
+		return errors.New("tlsRequireClientCert needs tlsClientCA")
+	case cfg.ClientCerts != "" && cfg.TLSClientCA == "":
+		return errors.New("clientCerts needs tlsClientCA")
+	case cfg.JWKS != "" && cfg.JWTAudience == "":
+		return errors.New("jwks needs jwtAudience")
+	case cfg.JWTLeeway < 0:
+		return errors.New("jwtLeeway cannot be negative")
+	}
+
+	switch {
+	case cfg.ExpireAfterMonths < 0:
+		return errors.New("expireAfterMonths cannot be negative")
+	case cfg.ExpireInactiveMonths < 0:
+		return errors.New("expireInactiveMonths cannot be negative")
+	case cfg.ExpirySweepInterval <= 0:
+		return errors.New("expirySweepInterval must be positive")
+	case cfg.MaxBodyBytes <= 0:
+		return errors.New("maxBodyBytes must be positive")
+	case cfg.MaxItems < 0:
+		return errors.New("maxItems cannot be negative")
+	case cfg.MaxStringLength < 0:
+		return errors.New("maxStringLength cannot be negative")
+	}
+
+	switch cfg.TraceExporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	case tracing.ExporterFile:
+		if cfg.TraceFile == "" {
+			return errors.New("the file trace exporter needs traceFile")
+		}
+	default:
+		return fmt.Errorf("unknown traceExporter %q", cfg.TraceExporter)
+	}
+	if cfg.TraceSampleRatio < 0 || cfg.TraceSampleRatio > 1 {
+		return fmt.Errorf("traceSampleRatio %v is not between 0 and 1", cfg.TraceSampleRatio)
+	}
+
+	for _, origin := range cfg.CORSOrigins {
+		if err := validateOrigin(origin); err != nil {
+			return fmt.Errorf("corsOrigins: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateOrigin checks that origin is "*" or a scheme and host with no
+// path, as browsers send in the Origin header.
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("invalid origin %q", origin)
+	}
+	return nil
+}
+
+// Flags are the command line flags that override the config.
+type Flags struct {
+	fs     *flag.FlagSet
+	path   string
+	port   string
+	print  bool
+	values Config
+}
+
+// BindFlags registers the config flags on fs. Call Load once fs is parsed.
+func BindFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	d := Default()
+	fs.StringVar(&f.path, "config", "", "path to a JSON config file, or set "+EnvConfigFile)
+	fs.BoolVar(&f.print, "print-config", false, "print the merged config as JSON and exit")
+	fs.StringVar(&f.values.Addr, "addr", d.Addr, "host and port to listen on")
+	fs.StringVar(&f.port, "port", "", "port to listen on, keeping the host from -addr")
+	fs.Var(&f.values.ReadTimeout, "read-timeout", "longest time to read a request, 0 for none (default "+d.ReadTimeout.String()+")")
+	fs.Var(&f.values.WriteTimeout, "write-timeout", "longest time to handle a request and write its response, 0 for none (default "+d.WriteTimeout.String()+")")
+	fs.Var(&f.values.IdleTimeout, "idle-timeout", "longest time a keep-alive connection waits for a request, 0 for none (default "+d.IdleTimeout.String()+")")
+	fs.Var(&f.values.RequestTimeout, "request-timeout", "deadline for handling each request, 0 for none (default "+d.RequestTimeout.String()+")")
+	fs.Var(&f.values.DrainDelay, "drain-delay", "how long to report not ready before shutting down, so load balancers stop sending traffic (default "+d.DrainDelay.String()+")")
+	fs.Var(&f.values.ShutdownGrace, "shutdown-grace", "how long in-flight requests get to finish on shutdown (default "+d.ShutdownGrace.String()+")")
+	fs.StringVar(&f.values.Storage, "storage", d.Storage, "repository backend: memory")
+	fs.StringVar(&f.values.LogLevel, "log-level", d.LogLevel, "lowest level logged: debug, info, warn or error")
+	fs.StringVar(&f.values.Rules, "rules", "", "path to a JSON ruleset file")
+	fs.StringVar(&f.values.TLSCert, "tls-cert", "", "PEM certificate to serve HTTPS with, reloaded when it changes")
+	fs.StringVar(&f.values.TLSKey, "tls-key", "", "PEM private key for -tls-cert")
+	fs.StringVar(&f.values.TLSClientCA, "tls-client-ca", "", "PEM CA bundle to verify client certificates against")
+	fs.BoolVar(&f.values.TLSRequireClientCert, "tls-require-client-cert", false, "refuse TLS connections without a verified client certificate")
+	fs.Var((*commaList)(&f.values.CORSOrigins), "cors-origins", "comma separated browser origins allowed to call the API, * for any")
+	fs.BoolVar(&f.values.InsecureAdmin, "insecure-admin", false, "open administrative routes to every caller when no authentication is configured")
+	fs.StringVar(&f.values.Tenants, "tenants", "", "path to a JSON file of tenants, each served from its own store")
+	fs.StringVar(&f.values.APIKeys, "api-keys", "", "path to a JSON file of hashed API keys, required on every request when set")
+	fs.StringVar(&f.values.ClientCerts, "client-certs", "", "path to a JSON file mapping client certificate subjects to clients, used with -tls-client-ca")
+	fs.StringVar(&f.values.JWKS, "jwks", "", "path to a JWKS file whose keys verify bearer tokens")
+	fs.StringVar(&f.values.JWTAudience, "jwt-audience", "", "audience bearer tokens must be issued for, required with -jwks")
+	fs.StringVar(&f.values.JWTIssuer, "jwt-issuer", "", "issuer bearer tokens must come from, any when empty")
+	fs.Var(&f.values.JWTLeeway, "jwt-leeway", "clock skew allowed when checking token expiry (default "+d.JWTLeeway.String()+")")
+	fs.StringVar(&f.values.RateLimits, "rate-limits", "", "path to a JSON file of per-route rate limits and daily quotas")
+	fs.IntVar(&f.values.ExpireAfterMonths, "expire-after-months", 0, "months after purchase that points expire, 0 to disable")
+	fs.IntVar(&f.values.ExpireInactiveMonths, "expire-inactive-months", 0, "months of account inactivity after which points expire, 0 to disable")
+	fs.Var(&f.values.ExpirySweepInterval, "expiry-sweep-interval", "how often to expire points (default "+d.ExpirySweepInterval.String()+")")
+	fs.Int64Var(&f.values.MaxBodyBytes, "max-body-bytes", d.MaxBodyBytes, "largest request body accepted")
+	fs.IntVar(&f.values.MaxItems, "max-items", d.MaxItems, "most items a receipt may list, 0 for no limit")
+	fs.IntVar(&f.values.MaxStringLength, "max-string-length", d.MaxStringLength, "longest retailer or item description accepted in bytes, 0 for no limit")
+	fs.BoolVar(&f.values.StrictJSON, "strict-json", false, "reject request bodies with unknown fields")
+	fs.StringVar(&f.values.TraceExporter, "trace-exporter", d.TraceExporter, "where to send trace spans: none, otlp, stdout or file")
+	fs.StringVar(&f.values.TraceFile, "trace-file", "", "file the file trace exporter appends spans to")
+	fs.Float64Var(&f.values.TraceSampleRatio, "trace-sample-ratio", d.TraceSampleRatio, "fraction of new traces recorded")
+	return f
+}
+
+// PrintConfig reports whether -print-config was given.
+func (f *Flags) PrintConfig() bool {
+	return f.print
+}
+
+// Load merges the defaults, the config file, environment variables read
+// with getenv and the flags that were set, then validates the result.
+func (f *Flags) Load(getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	path := f.path
+	if path == "" {
+		path = getenv(EnvConfigFile)
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return cfg, err
+		}
+	}
+	if err := cfg.loadEnv(getenv); err != nil {
+		return cfg, err
+	}
+
+	var flagErr error
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "addr":
+			cfg.Addr = f.values.Addr
+		case "port":
+			host, _, err := net.SplitHostPort(cfg.Addr)
+			if err != nil {
+				flagErr = fmt.Errorf("addr: %w", err)
+				return
+			}
+			cfg.Addr = net.JoinHostPort(host, f.port)
+		case "read-timeout":
+			cfg.ReadTimeout = f.values.ReadTimeout
+		case "write-timeout":
+			cfg.WriteTimeout = f.values.WriteTimeout
+		case "idle-timeout":
+			cfg.IdleTimeout = f.values.IdleTimeout
+		case "request-timeout":
+			cfg.RequestTimeout = f.values.RequestTimeout
+		case "drain-delay":
+			cfg.DrainDelay = f.values.DrainDelay
+		case "shutdown-grace":
+			cfg.ShutdownGrace = f.values.ShutdownGrace
+		case "storage":
+			cfg.Storage = f.values.Storage
+		case "log-level":
+			cfg.LogLevel = f.values.LogLevel
+		case "rules":
+			cfg.Rules = f.values.Rules
+		case "tls-cert":
+			cfg.TLSCert = f.values.TLSCert
+		case "tls-key":
+			cfg.TLSKey = f.values.TLSKey
+		case "tls-client-ca":
+			cfg.TLSClientCA = f.values.TLSClientCA
+		case "tls-require-client-cert":
+			cfg.TLSRequireClientCert = f.values.TLSRequireClientCert
+		case "cors-origins":
+			cfg.CORSOrigins = f.values.CORSOrigins
+		case "insecure-admin":
+			cfg.InsecureAdmin = f.values.InsecureAdmin
+		case "tenants":
+			cfg.Tenants = f.values.Tenants
+		case "api-keys":
+			cfg.APIKeys = f.values.APIKeys
+		case "client-certs":
+			cfg.ClientCerts = f.values.ClientCerts
+		case "jwks":
+			cfg.JWKS = f.values.JWKS
+		case "jwt-audience":
+			cfg.JWTAudience = f.values.JWTAudience
+		case "jwt-issuer":
+			cfg.JWTIssuer = f.values.JWTIssuer
+		case "jwt-leeway":
+			cfg.JWTLeeway = f.values.JWTLeeway
+		case "rate-limits":
+			cfg.RateLimits = f.values.RateLimits
+		case "expire-after-months":
+			cfg.ExpireAfterMonths = f.values.ExpireAfterMonths
+		case "expire-inactive-months":
+			cfg.ExpireInactiveMonths = f.values.ExpireInactiveMonths
+		case "expiry-sweep-interval":
+			cfg.ExpirySweepInterval = f.values.ExpirySweepInterval
+		case "max-body-bytes":
+			cfg.MaxBodyBytes = f.values.MaxBodyBytes
+		case "max-items":
+			cfg.MaxItems = f.values.MaxItems
+		case "max-string-length":
+			cfg.MaxStringLength = f.values.MaxStringLength
+		case "strict-json":
+			cfg.StrictJSON = f.values.StrictJSON
+		case "trace-exporter":
+			cfg.TraceExporter = f.values.TraceExporter
+		case "trace-file":
+			cfg.TraceFile = f.values.TraceFile
+		case "trace-sample-ratio":
+			cfg.TraceSampleRatio = f.values.TraceSampleRatio
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+	return cfg, cfg.Validate()
+}
+
+// loadFile overrides cfg with the fields set in the JSON file at path.
+// Relative file paths in it are resolved against the file's directory.
+func (cfg *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("could not unmarshal config %s: %w", path, err)
+	}
+
+	// The paths have no defaults, so any that are set came from the file.
+	for _, p := range []*string{
+		&cfg.Rules, &cfg.TLSCert, &cfg.TLSKey, &cfg.TLSClientCA, &cfg.Tenants, &cfg.APIKeys,
+		&cfg.ClientCerts, &cfg.JWKS, &cfg.RateLimits, &cfg.TraceFile,
+	} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(filepath.Dir(path), *p)
+		}
+	}
+	return nil
+}
+
+// loadEnv overrides cfg with the environment variables that are set, named
+// EnvPrefix followed by the setting in upper snake case, such as
+// RECEIPTS_READ_TIMEOUT.
+func (cfg *Config) loadEnv(getenv func(string) string) error {
+	strs := map[string]*string{
+		"ADDR":           &cfg.Addr,
+		"STORAGE":        &cfg.Storage,
+		"LOG_LEVEL":      &cfg.LogLevel,
+		"RULES":          &cfg.Rules,
+		"TLS_CERT":       &cfg.TLSCert,
+		"TLS_KEY":        &cfg.TLSKey,
+		"TLS_CLIENT_CA":  &cfg.TLSClientCA,
+		"TENANTS":        &cfg.Tenants,
+		"API_KEYS":       &cfg.APIKeys,
+		"CLIENT_CERTS":   &cfg.ClientCerts,
+		"JWKS":           &cfg.JWKS,
+		"JWT_AUDIENCE":   &cfg.JWTAudience,
+		"JWT_ISSUER":     &cfg.JWTIssuer,
+		"RATE_LIMITS":    &cfg.RateLimits,
+		"TRACE_EXPORTER": &cfg.TraceExporter,
+		"TRACE_FILE":     &cfg.TraceFile,
+	}
+	for name, dst := range strs {
+		if v := getenv(EnvPrefix + name); v != "" {
+			*dst = v
+		}
+	}
+
+	if v := getenv(EnvPrefix + "CORS_ORIGINS"); v != "" {
+		(*commaList)(&cfg.CORSOrigins).Set(v)
+	}
+	bools := map[string]*bool{
+		"TLS_REQUIRE_CLIENT_CERT": &cfg.TLSRequireClientCert,
+		"INSECURE_ADMIN":          &cfg.InsecureAdmin,
+		"STRICT_JSON":             &cfg.StrictJSON,
+	}
+	for name, dst := range bools {
+		v := getenv(EnvPrefix + name)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvPrefix+name, err)
+		}
+		*dst = parsed
+	}
+
+	durations := map[string]*Duration{
+		"READ_TIMEOUT":          &cfg.ReadTimeout,
+		"WRITE_TIMEOUT":         &cfg.WriteTimeout,
+		"IDLE_TIMEOUT":          &cfg.IdleTimeout,
+		"REQUEST_TIMEOUT":       &cfg.RequestTimeout,
+		"DRAIN_DELAY":           &cfg.DrainDelay,
+		"SHUTDOWN_GRACE":        &cfg.ShutdownGrace,
+		"JWT_LEEWAY":            &cfg.JWTLeeway,
+		"EXPIRY_SWEEP_INTERVAL": &cfg.ExpirySweepInterval,
+	}
+	for name, dst := range durations {
+		v := getenv(EnvPrefix + name)
+		if v == "" {
+			continue
+		}
+		if err := dst.Set(v); err != nil {
+			return fmt.Errorf("%s: %w", EnvPrefix+name, err)
+		}
+	}
+
+	ints := map[string]*int{
+		"EXPIRE_AFTER_MONTHS":    &cfg.ExpireAfterMonths,
+		"EXPIRE_INACTIVE_MONTHS": &cfg.ExpireInactiveMonths,
+		"MAX_ITEMS":              &cfg.MaxItems,
+		"MAX_STRING_LENGTH":      &cfg.MaxStringLength,
+	}
+	for name, dst := range ints {
+		v := getenv(EnvPrefix + name)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvPrefix+name, err)
+		}
+		*dst = parsed
+	}
+	if v := getenv(EnvPrefix + "MAX_BODY_BYTES"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%sMAX_BODY_BYTES: %w", EnvPrefix, err)
+		}
+		cfg.MaxBodyBytes = parsed
+	}
+	if v := getenv(EnvPrefix + "TRACE_SAMPLE_RATIO"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%sTRACE_SAMPLE_RATIO: %w", EnvPrefix, err)
+		}
+		cfg.TraceSampleRatio = parsed
+	}
+	return nil
+}
+
+// Duration is a time.Duration written as a string such as "10s" in JSON
+// and on the command line.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) Set(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"10s\": %w", err)
+	}
+	return d.Set(s)
+}
+
+// commaList is a list written as comma separated values in flags and
+// environment variables.
+type commaList []string
+
+func (l *commaList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *commaList) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
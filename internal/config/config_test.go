@@ -0,0 +1,320 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/controllers"
+)
+
+func Test_Load(t *testing.T) {
+	testCases := map[string]struct {
+		args        []string
+		env         map[string]string
+		expected    func(cfg *Config)
+		expectError bool
+	}{
+		"defaults": {
+			expected: func(cfg *Config) {},
+		},
+		"config file": {
+			args: []string{"-config", "testdata/config.json"},
+			expected: func(cfg *Config) {
+				cfg.Addr = "0.0.0.0:8080"
+				cfg.ReadTimeout = Duration(5 * time.Second)
+				cfg.ShutdownGrace = Duration(20 * time.Second)
+				cfg.LogLevel = "debug"
+				cfg.Rules = filepath.Join("testdata", "rules.json")
+			},
+		},
+		"config file from env": {
+			env: map[string]string{EnvConfigFile: "testdata/config.json"},
+			expected: func(cfg *Config) {
+				cfg.Addr = "0.0.0.0:8080"
+				cfg.ReadTimeout = Duration(5 * time.Second)
+				cfg.ShutdownGrace = Duration(20 * time.Second)
+				cfg.LogLevel = "debug"
+				cfg.Rules = filepath.Join("testdata", "rules.json")
+			},
+		},
+		"env overrides file": {
+			args: []string{"-config", "testdata/config.json"},
+			env: map[string]string{
+				"RECEIPTS_READ_TIMEOUT": "7s",
+				"RECEIPTS_LOG_LEVEL":    "warn",
+			},
+			expected: func(cfg *Config) {
+				cfg.Addr = "0.0.0.0:8080"
+				cfg.ReadTimeout = Duration(7 * time.Second)
+				cfg.ShutdownGrace = Duration(20 * time.Second)
+				cfg.LogLevel = "warn"
+				cfg.Rules = filepath.Join("testdata", "rules.json")
+			},
+		},
+		"flags override env": {
+			args: []string{"-read-timeout", "1s", "-port", "9000"},
+			env: map[string]string{
+				"RECEIPTS_READ_TIMEOUT": "7s",
+				"RECEIPTS_ADDR":         "0.0.0.0:8080",
+			},
+			expected: func(cfg *Config) {
+				cfg.Addr = "0.0.0.0:9000"
+				cfg.ReadTimeout = Duration(time.Second)
+			},
+		},
+		"unset flags keep env": {
+			args: []string{"-log-level", "error"},
+			env:  map[string]string{"RECEIPTS_ADDR": "0.0.0.0:8080"},
+			expected: func(cfg *Config) {
+				cfg.Addr = "0.0.0.0:8080"
+				cfg.LogLevel = "error"
+			},
+		},
+		"tls from env and flags": {
+			args: []string{"-tls-cert", "cert.pem", "-tls-key", "key.pem"},
+			env: map[string]string{
+				"RECEIPTS_TLS_CLIENT_CA":           "clients.pem",
+				"RECEIPTS_TLS_REQUIRE_CLIENT_CERT": "true",
+			},
+			expected: func(cfg *Config) {
+				cfg.TLSCert, cfg.TLSKey, cfg.TLSClientCA = "cert.pem", "key.pem", "clients.pem"
+				cfg.TLSRequireClientCert = true
+			},
+		},
+		"cors origins": {
+			args: []string{"-request-timeout", "2s"},
+			env:  map[string]string{"RECEIPTS_CORS_ORIGINS": "https://app.example.com, http://localhost:3000"},
+			expected: func(cfg *Config) {
+				cfg.RequestTimeout = Duration(2 * time.Second)
+				cfg.CORSOrigins = []string{"https://app.example.com", "http://localhost:3000"}
+			},
+		},
+		"cors origins flag overrides env": {
+			args: []string{"-cors-origins", "*"},
+			env:  map[string]string{"RECEIPTS_CORS_ORIGINS": "https://app.example.com"},
+			expected: func(cfg *Config) {
+				cfg.CORSOrigins = []string{"*"}
+			},
+		},
+		"insecure admin from env": {
+			env: map[string]string{"RECEIPTS_INSECURE_ADMIN": "true"},
+			expected: func(cfg *Config) {
+				cfg.InsecureAdmin = true
+			},
+		},
+		"service settings": {
+			args: []string{"-expire-after-months", "12", "-jwt-leeway", "30s", "-trace-exporter", "stdout", "-max-items", "20"},
+			env: map[string]string{
+				"RECEIPTS_TRACE_SAMPLE_RATIO":    "0.5",
+				"RECEIPTS_MAX_ITEMS":             "10",
+				"RECEIPTS_MAX_BODY_BYTES":        "2048",
+				"RECEIPTS_STRICT_JSON":           "true",
+				"RECEIPTS_EXPIRY_SWEEP_INTERVAL": "5m",
+				"RECEIPTS_JWT_ISSUER":            "https://issuer.example",
+			},
+			expected: func(cfg *Config) {
+				cfg.ExpireAfterMonths = 12
+				cfg.JWTLeeway = Duration(30 * time.Second)
+				cfg.TraceExporter = "stdout"
+				cfg.MaxItems = 20
+				cfg.TraceSampleRatio = 0.5
+				cfg.MaxBodyBytes = 2048
+				cfg.StrictJSON = true
+				cfg.ExpirySweepInterval = Duration(5 * time.Minute)
+				cfg.JWTIssuer = "https://issuer.example"
+			},
+		},
+		"invalid env int": {
+			env:         map[string]string{"RECEIPTS_MAX_ITEMS": "lots"},
+			expectError: true,
+		},
+		"invalid env bool": {
+			env:         map[string]string{"RECEIPTS_TLS_REQUIRE_CLIENT_CERT": "sometimes"},
+			expectError: true,
+		},
+		"missing config file": {
+			args:        []string{"-config", "testdata/missing.json"},
+			expectError: true,
+		},
+		"invalid env duration": {
+			env:         map[string]string{"RECEIPTS_IDLE_TIMEOUT": "soon"},
+			expectError: true,
+		},
+		"invalid flag value": {
+			args:        []string{"-storage", "postgres"},
+			expectError: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			f := BindFlags(fs)
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := f.Load(func(key string) string { return tc.env[key] })
+			if tc.expectError {
+				if err == nil {
+					t.Error("expected error but did not get one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			expected := Default()
+			tc.expected(&expected)
+			if !reflect.DeepEqual(cfg, expected) {
+				t.Errorf("unexpected config:\n got %+v\nwant %+v", cfg, expected)
+			}
+		})
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	testCases := map[string]struct {
+		modify      func(cfg *Config)
+		expectError bool
+	}{
+		"defaults": {
+			modify: func(cfg *Config) {},
+		},
+		"any interface": {
+			modify: func(cfg *Config) { cfg.Addr = ":8000" },
+		},
+		"no timeouts": {
+			modify: func(cfg *Config) {
+				cfg.ReadTimeout, cfg.WriteTimeout, cfg.IdleTimeout, cfg.DrainDelay = 0, 0, 0, 0
+			},
+		},
+		"missing port": {
+			modify:      func(cfg *Config) { cfg.Addr = "127.0.0.1" },
+			expectError: true,
+		},
+		"invalid port": {
+			modify:      func(cfg *Config) { cfg.Addr = "127.0.0.1:http-ish" },
+			expectError: true,
+		},
+		"negative timeout": {
+			modify:      func(cfg *Config) { cfg.WriteTimeout = Duration(-time.Second) },
+			expectError: true,
+		},
+		"no shutdown grace": {
+			modify:      func(cfg *Config) { cfg.ShutdownGrace = 0 },
+			expectError: true,
+		},
+		"unknown storage": {
+			modify:      func(cfg *Config) { cfg.Storage = "postgres" },
+			expectError: true,
+		},
+		"unknown log level": {
+			modify:      func(cfg *Config) { cfg.LogLevel = "verbose" },
+			expectError: true,
+		},
+		"tls": {
+			modify: func(cfg *Config) {
+				cfg.TLSCert, cfg.TLSKey, cfg.TLSClientCA = "cert.pem", "key.pem", "clients.pem"
+				cfg.TLSRequireClientCert = true
+			},
+		},
+		"tls cert without key": {
+			modify:      func(cfg *Config) { cfg.TLSCert = "cert.pem" },
+			expectError: true,
+		},
+		"client CA without tls": {
+			modify:      func(cfg *Config) { cfg.TLSClientCA = "clients.pem" },
+			expectError: true,
+		},
+		"required client cert without CA": {
+			modify: func(cfg *Config) {
+				cfg.TLSCert, cfg.TLSKey = "cert.pem", "key.pem"
+				cfg.TLSRequireClientCert = true
+			},
+			expectError: true,
+		},
+		"cors origins": {
+			modify: func(cfg *Config) { cfg.CORSOrigins = []string{"https://app.example.com", "http://localhost:3000", "*"} },
+		},
+		"cors origin with path": {
+			modify:      func(cfg *Config) { cfg.CORSOrigins = []string{"https://app.example.com/"} },
+			expectError: true,
+		},
+		"cors origin without scheme": {
+			modify:      func(cfg *Config) { cfg.CORSOrigins = []string{"app.example.com"} },
+			expectError: true,
+		},
+		"negative trace sample ratio": {
+			modify:      func(cfg *Config) { cfg.TraceSampleRatio = -0.5 },
+			expectError: true,
+		},
+		"unknown trace exporter": {
+			modify:      func(cfg *Config) { cfg.TraceExporter = "zipkin" },
+			expectError: true,
+		},
+		"file trace exporter without file": {
+			modify:      func(cfg *Config) { cfg.TraceExporter = "file" },
+			expectError: true,
+		},
+		"no expiry sweep interval": {
+			modify:      func(cfg *Config) { cfg.ExpirySweepInterval = 0 },
+			expectError: true,
+		},
+		"negative expiry": {
+			modify:      func(cfg *Config) { cfg.ExpireInactiveMonths = -1 },
+			expectError: true,
+		},
+		"no body limit": {
+			modify:      func(cfg *Config) { cfg.MaxBodyBytes = 0 },
+			expectError: true,
+		},
+		"negative item limit": {
+			modify:      func(cfg *Config) { cfg.MaxItems = -1 },
+			expectError: true,
+		},
+		"jwks without audience": {
+			modify:      func(cfg *Config) { cfg.JWKS = "testdata/config.json" },
+			expectError: true,
+		},
+		"client certs without CA": {
+			modify:      func(cfg *Config) { cfg.ClientCerts = "testdata/config.json" },
+			expectError: true,
+		},
+		"missing tenants file": {
+			modify:      func(cfg *Config) { cfg.Tenants = "testdata/missing.json" },
+			expectError: true,
+		},
+		"missing rules file": {
+			modify:      func(cfg *Config) { cfg.Rules = "testdata/missing.json" },
+			expectError: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			cfg := Default()
+			tc.modify(&cfg)
+			err := cfg.Validate()
+			if tc.expectError && err == nil {
+				t.Error("expected error but did not get one")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		})
+	}
+}
+
+func Test_Default_requestLimits(t *testing.T) {
+	d := Default()
+	limits := controllers.DefaultRequestLimits()
+	if d.MaxBodyBytes != limits.MaxBodyBytes || d.MaxItems != limits.MaxItems || d.MaxStringLength != limits.MaxStringLength {
+		t.Errorf("config defaults differ from the controller's: %d, %d, %d", d.MaxBodyBytes, d.MaxItems, d.MaxStringLength)
+	}
+}
@@ -1,16 +1,27 @@
 package controllers
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/expiration"
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+	"github.com/gpayne44/fetch-challenge/internal/metrics"
+	"github.com/gpayne44/fetch-challenge/internal/process"
 	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"github.com/gpayne44/fetch-challenge/internal/risk"
+	"github.com/gpayne44/fetch-challenge/internal/tiers"
 )
 
 var (
-	errFmtReadingRequest    = "error reading request body: %s"
 	errFmtUnmarshalRequest  = "could not unmarshal request: %s"
+	errFmtBodyTooLarge      = "request body is larger than %d bytes"
+	errFmtReceiptTooLarge   = "The receipt is too large: %s."
 	errFmtCalculatePoints   = "error calculating point total: %v"
 	errFmtStoreReceipt      = "error storing receipt: %s"
 	errFmtMarshalIDResponse = "receipt processed, could not marshal new ID: %s"
@@ -18,24 +29,220 @@ var (
 	errFmtMarshalResponse   = "could not marhsal response: %s"
 	errFmtInvalidReceiptID  = "could not parse id param %s: %s"
 
-	errMsgInvalidReceipt = "The receipt is invalid."
-	errEmptyID           = "empty ID in request path"
-	errNoReceiptFound    = "No receipt found for that ID."
+	errFmtUserReadError     = "error reading user %s: %s"
+	errFmtCreateUser        = "error creating user: %s"
+	errFmtInvalidUserHeader = "could not parse %s header %s: %s"
+	errFmtCreateReward      = "error creating reward: %s"
+	errFmtRewardReadError   = "error reading rewards: %s"
+	errFmtRedeem            = "error redeeming reward: %s"
+	errFmtInvalidDays       = "could not parse days param %s"
+	errFmtTierReadError     = "error computing tier for user %s: %s"
+	errFmtVoidReceipt       = "error voiding receipt %s: %s"
+	errFmtReviewReceipt     = "error reviewing receipt %s: %s"
+	errFmtListReceipts      = "error listing receipts: %s"
+	errFmtInvalidStatus     = "unknown receipt status %s"
+	errFmtSubjectReadError  = "error reading user for subject %s: %s"
+	errFmtUnknownTenant     = "unknown tenant %q"
+	errFmtTenantMismatch    = "credentials do not belong to tenant %q"
+
+	errMsgInvalidReceipt      = "The receipt is invalid."
+	errMsgInvalidUser         = "The user is invalid."
+	errEmptyID                = "empty ID in request path"
+	errNoReceiptFound         = "No receipt found for that ID."
+	errNoUserFound            = "No user found for that ID."
+	errMsgInvalidReward       = "The reward is invalid."
+	errNoRewardFound          = "No reward found for that ID."
+	errNoRedemptionFound      = "No redemption found for that ID."
+	errNoUserOrRewardFound    = "No user or reward found for that ID."
+	errMsgEmptyVoidReason     = "A reason is required to void a receipt."
+	errMsgAlreadyVoided       = "The receipt is already voided."
+	errMsgNotPending          = "The receipt is not pending review."
+	errMsgOwnerMismatch       = "Receipts can only be credited to the signed-in user."
+	errMsgUntrustedUserHeader = "Receipts can only be credited to a user by an authenticated client."
+	errMsgOtherUser           = "Only the signed-in user's account can be used."
+	errMsgAdminDisabled       = "Administrative routes need authentication to be configured."
+	errMsgTenantRequired      = "The credentials are not tied to a tenant and may not name one."
+	errMsgTimeout             = "The request took too long. Please try again."
+
+	errTrailingData = errors.New("unexpected data after JSON value")
+)
+
+const (
+	// headerUserID identifies the user that processed receipts are
+	// credited to. It is only trusted from authenticated integrations, and
+	// requests authenticated with a token subject are credited to that
+	// subject's user instead.
+	headerUserID = "X-User-ID"
+
+	idPattern = "{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}"
 )
 
 type controller struct {
-	repository repositories.ReceiptsRepository
-	logger     log.Logger
+	repository repositories.Repository
+	ruleset    *process.Ruleset
+	expiration expiration.Policy
+	tiers      tiers.Tiers
+	risk       risk.Evaluator
+	logger     *slog.Logger
+	now        func() time.Time
+	// authorize turns on scope checks for administrative routes.
+	authorize bool
+	// insecureAdmin opens administrative routes to every caller when
+	// authorization is off. Otherwise they are refused.
+	insecureAdmin bool
+	// tenants holds each tenant's repository and ruleset, keyed by tenant
+	// ID. It is nil when the service hosts a single program.
+	tenants map[string]tenant
+	limits  RequestLimits
+	metrics *metrics.Metrics
+	// trace records a span for every repository operation.
+	trace bool
+}
+
+// Option configures optional controller dependencies.
+type Option func(*controller)
+
+// WithRuleset scores receipts with rs instead of the default ruleset.
+func WithRuleset(rs *process.Ruleset) Option {
+	return func(c *controller) {
+		c.ruleset = rs
+	}
+}
+
+// WithExpirationPolicy reports expiring points according to policy. Points
+// never expire by default.
+func WithExpirationPolicy(policy expiration.Policy) Option {
+	return func(c *controller) {
+		c.expiration = policy
+	}
+}
+
+// WithTiers replaces the default membership tiers.
+func WithTiers(ts tiers.Tiers) Option {
+	return func(c *controller) {
+		c.tiers = ts
+	}
 }
 
-func New(repository repositories.ReceiptsRepository) *controller {
-	return &controller{
+// WithRiskEvaluator replaces the default risk checks. A nil evaluator turns
+// risk scoring off.
+func WithRiskEvaluator(e risk.Evaluator) Option {
+	return func(c *controller) {
+		c.risk = e
+	}
+}
+
+// WithAuthorization requires callers of administrative routes to hold the
+// matching scope. Administrative routes are refused to every caller by
+// default, so this should be set whenever authentication is.
+func WithAuthorization() Option {
+	return func(c *controller) {
+		c.authorize = true
+	}
+}
+
+// WithInsecureAdmin opens administrative routes to every caller when
+// authorization is off, for local development.
+func WithInsecureAdmin() Option {
+	return func(c *controller) {
+		c.insecureAdmin = true
+	}
+}
+
+// WithLogger logs through logger instead of the default logger. Lines
+// written while handling a request also carry the attributes added by
+// logging.Middleware.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *controller) {
+		c.logger = logger
+	}
+}
+
+// WithMetrics records processed receipts and rejected requests in m.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(c *controller) {
+		c.metrics = m
+	}
+}
+
+// WithTracing records a span for each repository operation a request makes,
+// under the request's span.
+func WithTracing() Option {
+	return func(c *controller) {
+		c.trace = true
+	}
+}
+
+func New(repository repositories.Repository, opts ...Option) *controller {
+	c := &controller{
 		repository: repository,
-		logger:     *log.Default(),
+		ruleset:    process.DefaultRuleset(),
+		tiers:      tiers.Default(),
+		risk:       risk.NewDefault(risk.DefaultConfig()),
+		limits:     DefaultRequestLimits(),
+		logger:     slog.Default(),
+		now:        time.Now,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *controller) Register(router *mux.Router) {
 	router.HandleFunc("/receipts/process", c.ProcessReceipt()).Methods(http.MethodPost)
-	router.HandleFunc("/receipts/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/points", c.GetReceiptPoints()).Methods(http.MethodGet)
+	router.HandleFunc("/receipts/"+idPattern+"/points", c.GetReceiptPoints()).Methods(http.MethodGet)
+	router.Handle("/receipts/"+idPattern+"/void", c.restrict(auth.ScopeReceiptsVoid, c.VoidReceipt())).Methods(http.MethodPost)
+	router.Handle("/admin/receipts", c.restrict(auth.ScopeReceiptsReview, c.ListReviewReceipts())).Methods(http.MethodGet)
+	router.Handle("/admin/receipts/"+idPattern+"/approve", c.restrict(auth.ScopeReceiptsReview, c.ReviewReceipt(true))).Methods(http.MethodPost)
+	router.Handle("/admin/receipts/"+idPattern+"/reject", c.restrict(auth.ScopeReceiptsReview, c.ReviewReceipt(false))).Methods(http.MethodPost)
+	router.HandleFunc("/users", c.CreateUser()).Methods(http.MethodPost)
+	router.HandleFunc("/users/"+idPattern+"/balance", c.GetUserBalance()).Methods(http.MethodGet)
+	router.HandleFunc("/users/"+idPattern+"/ledger", c.GetUserLedger()).Methods(http.MethodGet)
+	router.HandleFunc("/users/"+idPattern+"/expiring", c.GetExpiringPoints()).Methods(http.MethodGet)
+	router.HandleFunc("/users/"+idPattern+"/tier", c.GetUserTier()).Methods(http.MethodGet)
+	router.HandleFunc("/users/"+idPattern+"/redemptions", c.RedeemReward()).Methods(http.MethodPost)
+	router.Handle("/rewards", c.restrict(auth.ScopeRewardsWrite, c.CreateReward())).Methods(http.MethodPost)
+	router.HandleFunc("/rewards", c.ListRewards()).Methods(http.MethodGet)
+	router.HandleFunc("/rewards/"+idPattern, c.GetReward()).Methods(http.MethodGet)
+	router.HandleFunc("/redemptions/"+idPattern, c.GetRedemption()).Methods(http.MethodGet)
+	router.HandleFunc("/redemptions/"+idPattern+"/cancel", c.CancelRedemption()).Methods(http.MethodPost)
+}
+
+// restrict wraps h so only callers holding scope may use it. Without
+// authorization no caller may, unless administrative routes were opened
+// with WithInsecureAdmin.
+func (c *controller) restrict(scope string, h http.HandlerFunc) http.Handler {
+	switch {
+	case c.authorize:
+		return auth.Require(scope)(h)
+	case c.insecureAdmin:
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.writeError(w, r, http.StatusForbidden, errMsgAdminDisabled)
+	})
+}
+
+// log returns the logger for the request.
+func (c *controller) log(r *http.Request) *slog.Logger {
+	return logging.FromContext(r.Context(), c.logger)
+}
+
+// writeError logs msg and writes it as the body of a response with status.
+// Server errors are logged as errors and client errors as warnings.
+func (c *controller) writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	// Failures caused by the request running out of time are reported as
+	// such rather than as server errors.
+	if status >= http.StatusInternalServerError && errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		c.log(r).Error(msg, "status", status)
+		status, msg = http.StatusServiceUnavailable, errMsgTimeout
+	}
+	level := slog.LevelWarn
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	c.log(r).Log(r.Context(), level, msg, "status", status)
+	w.WriteHeader(status)
+	w.Write([]byte(msg))
 }
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+const goldenReceiptsDir = "../../testdata/receipts"
+
+// Test_golden submits every receipt in testdata/receipts through the HTTP
+// handlers and checks the points reported by the API against the golden
+// files maintained by the process package tests.
+func Test_golden(t *testing.T) {
+	m := repositories.New()
+	c := New(m)
+
+	r := mux.NewRouter()
+	c.Register(r)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	paths, err := filepath.Glob(filepath.Join(goldenReceiptsDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no receipts found in golden corpus")
+	}
+
+	for _, path := range paths {
+		caseName := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(caseName, func(t *testing.T) {
+			body, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden, err := os.ReadFile(strings.TrimSuffix(path, ".json") + ".golden")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var expected entities.PointsResponse
+			if err := json.Unmarshal(golden, &expected); err != nil {
+				t.Fatalf("could not unmarshal golden file: %s", err.Error())
+			}
+
+			res, err := http.Post(srv.URL+endpointProcess, "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("error sending process request: %s", err.Error())
+			}
+			resBody, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status code: got %d, want %d: %s", res.StatusCode, http.StatusOK, resBody)
+			}
+			var idRes entities.ProcessResponse
+			if err := json.Unmarshal(resBody, &idRes); err != nil {
+				t.Fatalf("error unmarshal process response: %s", err.Error())
+			}
+
+			res, err = http.Get(srv.URL + fmt.Sprintf(endpointGetPoints, idRes.ID))
+			if err != nil {
+				t.Fatalf("error sending points request: %s", err.Error())
+			}
+			resBody, err = io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status code: got %d, want %d: %s", res.StatusCode, http.StatusOK, resBody)
+			}
+			var pointsRes entities.PointsResponse
+			if err := json.Unmarshal(resBody, &pointsRes); err != nil {
+				t.Fatalf("error unmarshal points response: %s", err.Error())
+			}
+			if pointsRes.Points != expected.Points {
+				t.Errorf("unexpected points: got %d, want %d", pointsRes.Points, expected.Points)
+			}
+		})
+	}
+}
@@ -3,111 +3,266 @@ package controllers
 import (
 	"encoding/json"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
 	"github.com/gpayne44/fetch-challenge/internal/entities"
-	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+	"github.com/gpayne44/fetch-challenge/internal/metrics"
 	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"github.com/gpayne44/fetch-challenge/internal/risk"
+	"github.com/gpayne44/fetch-challenge/internal/spans"
+	"github.com/gpayne44/fetch-challenge/internal/tiers"
+	"github.com/gpayne44/fetch-challenge/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func (c *controller) ProcessReceipt() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		b, err := io.ReadAll(r.Body)
-		if err != nil {
-			c.logger.Printf(errFmtReadingRequest, err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf(errFmtReadingRequest, err.Error())))
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
 			return
 		}
 
-		var receipt entities.Receipt
-		err = json.Unmarshal(b, &receipt)
-		if err != nil {
-			c.logger.Printf(errFmtUnmarshalRequest, err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf(errFmtUnmarshalRequest, err.Error())))
+		receipt, ok := c.validateReceipt(w, r)
+		if !ok {
 			return
 		}
 
-		valid := receipt.Validate()
-		if !valid {
-			c.logger.Println(errMsgInvalidReceipt)
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(errMsgInvalidReceipt))
-			return
+		var ownerID uuid.UUID
+		identity, _ := auth.FromContext(r.Context())
+		if identity.Subject != "" {
+			user, err := tenant.repository.UserForSubject(identity.Subject)
+			if err != nil {
+				c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtSubjectReadError, identity.Subject, err.Error()))
+				return
+			}
+			if userHeader := r.Header.Get(headerUserID); userHeader != "" && userHeader != user.ID {
+				c.writeError(w, r, http.StatusForbidden, errMsgOwnerMismatch)
+				return
+			}
+			ownerID = uuid.MustParse(user.ID)
+		} else if userHeader := r.Header.Get(headerUserID); userHeader != "" {
+			if identity.ClientID == "" {
+				c.writeError(w, r, http.StatusForbidden, errMsgUntrustedUserHeader)
+				return
+			}
+			userID, err := uuid.Parse(userHeader)
+			if err != nil {
+				c.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(errFmtInvalidUserHeader, headerUserID, userHeader, err.Error()))
+				return
+			}
+			_, err = tenant.repository.GetUser(userID)
+			if err == repositories.ErrNotFound {
+				c.writeError(w, r, http.StatusBadRequest, errMsgInvalidUser)
+				return
+			} else if err != nil {
+				c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtUserReadError, userID.String(), err.Error()))
+				return
+			}
+			ownerID = userID
+		}
+
+		// Members earn their tier's multiple of the base points.
+		var tier *tiers.Tier
+		if ownerID != uuid.Nil {
+			current, _, _, err := c.userTier(tenant.repository, ownerID)
+			if err != nil {
+				c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtTierReadError, ownerID.String(), err.Error()))
+				return
+			}
+			tier = &current
 		}
 
-		pointTotal, processErrors := process.CalculatePoints(receipt)
+		score, processErrors := tenant.ruleset.ScoreForTier(r.Context(), receipt, tier)
 		if len(processErrors) != 0 {
-			c.logger.Printf(errFmtCalculatePoints, processErrors)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf(errFmtCalculatePoints, processErrors)))
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtCalculatePoints, processErrors))
 			return
 		}
 
-		record := entities.ReceiptRecord{Points: pointTotal, Receipt: receipt}
-		newID, err := c.repository.StoreReceipt(record)
+		record := entities.ReceiptRecord{Points: score.Total, BasePoints: score.Base, Tier: score.Tier, Receipt: receipt}
+		if ownerID != uuid.Nil {
+			record.OwnerID = ownerID.String()
+		}
+
+		record.ClientID = identity.ClientID
+
+		record.Status = entities.ReceiptStatusAccepted
+		if c.risk != nil {
+			submitter := record.OwnerID
+			if submitter == "" {
+				submitter = record.ClientID
+			}
+			if submitter == "" {
+				submitter = clientAddr(r)
+			}
+			// Risk history is shared by every tenant, so one tenant's
+			// traffic must not count against another's submitters.
+			if tenant.id != "" {
+				submitter = tenant.id + "/" + submitter
+			}
+			assessment := c.risk.Evaluate(risk.Input{Receipt: receipt, Submitter: submitter, Now: c.now()})
+			record.RiskScore = assessment.Score
+			record.RiskReasons = assessment.Reasons
+			if assessment.Hold {
+				c.log(r).Info("holding receipt for review", "risk_score", assessment.Score, "risk_reasons", assessment.Reasons,
+					"implausible_fields", assessment.Fields)
+				record.Status = entities.ReceiptStatusPending
+			}
+		}
+		newID, err := tenant.repository.StoreReceipt(record)
 		if err != nil {
-			c.logger.Printf(errFmtStoreReceipt, err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf(errFmtStoreReceipt, err.Error())))
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtStoreReceipt, err.Error()))
 			return
 		}
+		logging.With(r.Context(), "receipt_id", newID, "points", record.Points, "receipt_status", record.Status)
+		c.metrics.ReceiptProcessed(record.Status, score)
+
+		processResponse := entities.ProcessResponse{ID: newID}
+		if record.Status == entities.ReceiptStatusPending {
+			processResponse.Status = record.Status
+		}
 
-		resBytes, err := json.Marshal(entities.ProcessResponse{ID: newID})
+		resBytes, err := json.Marshal(processResponse)
 		if err != nil {
-			c.logger.Printf(errFmtMarshalIDResponse, err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf(errFmtMarshalIDResponse, err.Error())))
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtMarshalIDResponse, err.Error()))
 			return
 		}
 		w.Write(resBytes)
 	}
 }
 
+// validateReceipt decodes and validates the receipt in the request body,
+// writing an error response if it is not acceptable.
+func (c *controller) validateReceipt(w http.ResponseWriter, r *http.Request) (entities.Receipt, bool) {
+	_, span := tracing.Start(r.Context(), "validate receipt")
+	defer span.End()
+
+	var receipt entities.Receipt
+	if !c.decodeBody(w, r, &receipt, false) {
+		span.SetStatus(codes.Error, errMsgInvalidReceipt)
+		return receipt, false
+	}
+	if err := receipt.CheckSize(c.limits.MaxItems, c.limits.MaxStringLength); err != nil {
+		spans.Fail(span, err)
+		c.metrics.ValidationFailed(metrics.ReasonReceiptTooBig)
+		c.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(errFmtReceiptTooLarge, err.Error()))
+		return receipt, false
+	}
+
+	if field := receipt.InvalidField(); field != "" {
+		span.SetStatus(codes.Error, errMsgInvalidReceipt)
+		span.SetAttributes(attribute.String("invalid_field", field))
+		c.metrics.ValidationFailed(metrics.ReasonInvalidPrefix + field)
+		c.writeError(w, r, http.StatusBadRequest, errMsgInvalidReceipt)
+		return receipt, false
+	}
+	span.SetAttributes(attribute.Int("items", len(receipt.Items)))
+	return receipt, true
+}
+
 func (c *controller) GetReceiptPoints() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
 		idParam := mux.Vars(r)["id"]
 		if idParam == "" {
-			c.logger.Println(errEmptyID)
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(errEmptyID))
+			c.writeError(w, r, http.StatusBadRequest, errEmptyID)
 			return
 		}
 
 		parsedID, err := uuid.Parse(idParam)
 		if err != nil {
-			c.logger.Println(errFmtInvalidReceiptID, idParam, err.Error())
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(fmt.Sprintf(errFmtInvalidReceiptID, idParam, err.Error())))
+			c.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(errFmtInvalidReceiptID, idParam, err.Error()))
 			return
 		}
+		logging.With(r.Context(), "receipt_id", parsedID.String())
 
-		record, err := c.repository.GetReceipt(parsedID)
+		record, err := tenant.repository.GetReceipt(parsedID)
 		if err != nil {
 			if err == repositories.ErrNotFound {
-				c.logger.Println(errNoReceiptFound)
-				w.WriteHeader(http.StatusNotFound)
-				w.Write([]byte(errNoReceiptFound))
+				c.writeError(w, r, http.StatusNotFound, errNoReceiptFound)
 				return
 			} else if err != repositories.ErrNotFound {
-				c.logger.Printf(errFmtReceiptReadError, parsedID.String(), err.Error())
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(fmt.Sprintf(errFmtReceiptReadError, parsedID.String(), err.Error())))
+				c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtReceiptReadError, parsedID.String(), err.Error()))
 				return
 			}
 		}
 
-		resBytes, err := json.Marshal(entities.PointsResponse{Points: record.Points})
+		pointsResponse := entities.PointsResponse{Points: record.Points}
+		switch {
+		case record.Voided():
+			pointsResponse = entities.PointsResponse{Status: entities.ReceiptStatusVoided, Reason: record.VoidReason}
+		case record.Status == entities.ReceiptStatusPending, record.Status == entities.ReceiptStatusRejected:
+			pointsResponse = entities.PointsResponse{Status: record.Status, Reason: record.ReviewNote}
+		}
+
+		resBytes, err := json.Marshal(pointsResponse)
 		if err != nil {
-			c.logger.Printf(errFmtMarshalResponse, err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf(errFmtMarshalResponse, err.Error())))
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtMarshalResponse, err.Error()))
 			return
 		}
 		w.Write(resBytes)
 	}
 }
+
+func (c *controller) VoidReceipt() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		receiptID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+		logging.With(r.Context(), "receipt_id", receiptID.String())
+
+		var req entities.VoidRequest
+		if !c.decodeBody(w, r, &req, false) {
+			return
+		}
+		if strings.TrimSpace(req.Reason) == "" {
+			c.writeError(w, r, http.StatusBadRequest, errMsgEmptyVoidReason)
+			return
+		}
+
+		// Points of the receipt that already expired are not taken back
+		// again.
+		record, err := tenant.repository.VoidReceipt(receiptID, req.Reason, func(entries []entities.LedgerEntry) int {
+			return c.expiration.Reversible(entries, receiptID.String())
+		})
+		switch err {
+		case nil:
+		case repositories.ErrNotFound:
+			c.writeError(w, r, http.StatusNotFound, errNoReceiptFound)
+			return
+		case repositories.ErrAlreadyVoided:
+			c.writeError(w, r, http.StatusConflict, errMsgAlreadyVoided)
+			return
+		default:
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtVoidReceipt, receiptID.String(), err.Error()))
+			return
+		}
+
+		c.writeResponse(w, r, entities.PointsResponse{Status: entities.ReceiptStatusVoided, Reason: record.VoidReason})
+	}
+}
+
+// clientAddr returns the host of the request's remote address.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -7,10 +7,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
 	"github.com/gpayne44/fetch-challenge/internal/entities"
 	"github.com/gpayne44/fetch-challenge/internal/repositories"
 )
@@ -152,6 +154,165 @@ func Test_ProcessReceipt(t *testing.T) {
 	}
 }
 
+func Test_ProcessReceipt_heldForRisk(t *testing.T) {
+	m := repositories.New()
+	c := New(m)
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(integrationAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var user entities.CreateUserResponse
+	doJSON(t, http.MethodPost, srv.URL+endpointUsers, `{"name":"Ada"}`, nil, &user)
+
+	testCases := map[string]string{
+		"future dated":                 strings.Replace(validReceipt, "2022-01-01", "2999-01-01", 1),
+		"items do not add up to total": strings.Replace(validReceipt, `"total": "35.35"`, `"total": "135.35"`, 1),
+	}
+
+	for caseName, input := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			var receipt entities.ProcessResponse
+			status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, input, map[string]string{headerUserID: user.ID}, &receipt)
+			if status != http.StatusOK {
+				t.Fatalf("unexpected status code: got %d, want %d", status, http.StatusOK)
+			}
+			if receipt.Status != entities.ReceiptStatusPending {
+				t.Errorf("unexpected process status: got %q, want %q", receipt.Status, entities.ReceiptStatusPending)
+			}
+
+			var points entities.PointsResponse
+			doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointGetPoints, receipt.ID), "", nil, &points)
+			if points.Status != entities.ReceiptStatusPending || points.Points != 0 {
+				t.Errorf("unexpected points response for held receipt: %+v", points)
+			}
+
+			var balance entities.BalanceResponse
+			doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserBalance, user.ID), "", nil, &balance)
+			if balance.Balance != 0 {
+				t.Errorf("held receipt was credited: balance %d", balance.Balance)
+			}
+
+			record, err := m.GetReceipt(uuid.MustParse(receipt.ID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.RiskScore == 0 || len(record.RiskReasons) == 0 {
+				t.Errorf("risk assessment not stored: %+v", record)
+			}
+		})
+	}
+}
+
+func Test_ProcessReceipt_recordsClient(t *testing.T) {
+	m := repositories.New()
+	c := New(m)
+
+	keys, err := auth.NewAPIKeys([]auth.APIKey{{ClientID: "partner-a", Hash: auth.HashKey("secret")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(keys))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	if status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("unexpected status code without key: got %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	var receipt entities.ProcessResponse
+	status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, map[string]string{auth.HeaderAPIKey: "secret"}, &receipt)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d, want %d", status, http.StatusOK)
+	}
+	record, err := m.GetReceipt(uuid.MustParse(receipt.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ClientID != "partner-a" {
+		t.Errorf("unexpected client id: got %q, want %q", record.ClientID, "partner-a")
+	}
+}
+
+// subjectAuthenticator signs requests in as the subject in their
+// Authorization header. The subject "admin" holds the admin scope.
+type subjectAuthenticator struct{}
+
+func (subjectAuthenticator) Authenticate(r *http.Request) (auth.Identity, error) {
+	subject := r.Header.Get("Authorization")
+	if subject == "" {
+		return auth.Identity{}, auth.ErrNoCredentials
+	}
+	id := auth.Identity{Subject: subject, ClientID: "mobile-app"}
+	if subject == "admin" {
+		id.Scopes = []string{auth.ScopeAdmin}
+	}
+	return id, nil
+}
+
+func Test_ProcessReceipt_creditsSubject(t *testing.T) {
+	m := repositories.New()
+	c := New(m)
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(subjectAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	process := func(headers map[string]string) (*entities.ReceiptRecord, int) {
+		var receipt entities.ProcessResponse
+		status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, headers, &receipt)
+		if status != http.StatusOK {
+			return nil, status
+		}
+		record, err := m.GetReceipt(uuid.MustParse(receipt.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record, status
+	}
+
+	first, status := process(map[string]string{"Authorization": "user-123"})
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d, want %d", status, http.StatusOK)
+	}
+	if first.OwnerID == "" {
+		t.Fatal("expected receipt to be credited to the subject's user")
+	}
+
+	second, status := process(map[string]string{"Authorization": "user-123", headerUserID: first.OwnerID})
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d, want %d", status, http.StatusOK)
+	}
+	if second.OwnerID != first.OwnerID {
+		t.Errorf("unexpected owner id: got %q, want %q", second.OwnerID, first.OwnerID)
+	}
+
+	other, err := m.CreateUser(entities.User{Name: "someone else"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, status := process(map[string]string{"Authorization": "user-123", headerUserID: other}); status != http.StatusForbidden {
+		t.Errorf("unexpected status code crediting another user: got %d, want %d", status, http.StatusForbidden)
+	}
+
+	balance, err := m.GetBalance(uuid.MustParse(first.OwnerID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != first.Points+second.Points {
+		t.Errorf("unexpected balance: got %d, want %d", balance, first.Points+second.Points)
+	}
+}
+
 func Test_GetReceiptPoints(t *testing.T) {
 	m := repositories.New()
 	c := New(m)
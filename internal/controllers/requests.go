@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gpayne44/fetch-challenge/internal/metrics"
+)
+
+// RequestLimits bounds the size of request bodies and receipts.
+type RequestLimits struct {
+	// MaxBodyBytes is the largest request body accepted.
+	MaxBodyBytes int64
+	// DisallowUnknownFields rejects bodies with fields the request type
+	// does not define.
+	DisallowUnknownFields bool
+	// MaxItems is the most items a receipt may list.
+	MaxItems int
+	// MaxStringLength is the longest a receipt's retailer or item
+	// description may be, in bytes.
+	MaxStringLength int
+}
+
+// DefaultRequestLimits returns limits generous enough for any real receipt.
+func DefaultRequestLimits() RequestLimits {
+	return RequestLimits{
+		MaxBodyBytes:    1 << 20,
+		MaxItems:        500,
+		MaxStringLength: 256,
+	}
+}
+
+// WithRequestLimits replaces the default request limits.
+func WithRequestLimits(limits RequestLimits) Option {
+	return func(c *controller) {
+		c.limits = limits
+	}
+}
+
+// decodeBody decodes the single JSON value in the request body into v,
+// writing an error response if it cannot. Bodies over the size limit are
+// rejected with 413 Request Entity Too Large. An empty body is accepted
+// only if the body is optional.
+func (c *controller) decodeBody(w http.ResponseWriter, r *http.Request, v interface{}, optional bool) bool {
+	body := http.MaxBytesReader(w, r.Body, c.limits.MaxBodyBytes)
+	dec := json.NewDecoder(body)
+	if c.limits.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	err := dec.Decode(v)
+	if err == io.EOF && optional {
+		return true
+	}
+	if err == nil {
+		// Anything but the end of the body after the value is rejected,
+		// which also catches bodies that only go over the limit there.
+		_, err = dec.Token()
+		if err == io.EOF {
+			return true
+		}
+		var maxBytesErr *http.MaxBytesError
+		if !errors.As(err, &maxBytesErr) {
+			err = errTrailingData
+		}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.metrics.ValidationFailed(metrics.ReasonBodyTooLarge)
+		c.writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf(errFmtBodyTooLarge, maxBytesErr.Limit))
+		return false
+	}
+	c.metrics.ValidationFailed(metrics.ReasonMalformedJSON)
+	c.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(errFmtUnmarshalRequest, err.Error()))
+	return false
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/metrics"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+func Test_ProcessReceipt_requestLimits(t *testing.T) {
+	limits := RequestLimits{
+		MaxBodyBytes:    2048,
+		MaxItems:        5,
+		MaxStringLength: 32,
+	}
+	strict := limits
+	strict.DisallowUnknownFields = true
+
+	manyItems := strings.Replace(validReceipt, `"items": [`, `"items": [{"shortDescription": "Gum", "price": "1.00"},`, 1)
+	longRetailer := strings.Replace(validReceipt, `"Target"`, `"`+strings.Repeat("Target ", 5)+`"`, 1)
+	longDescription := strings.Replace(validReceipt, "Emils Cheese Pizza", strings.Repeat("Pizza ", 6), 1)
+	unknownField := strings.Replace(validReceipt, `"retailer"`, `"coupon": "SAVE10", "retailer"`, 1)
+	largeBody := strings.Replace(validReceipt, `"retailer"`, `"padding": "`+strings.Repeat("x", 2048)+`", "retailer"`, 1)
+
+	testCases := map[string]struct {
+		limits        RequestLimits
+		body          string
+		expStatusCode int
+	}{
+		"within limits": {
+			limits:        limits,
+			body:          validReceipt,
+			expStatusCode: http.StatusOK,
+		},
+		"body too large": {
+			limits:        limits,
+			body:          largeBody,
+			expStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		"unknown field allowed by default": {
+			limits:        limits,
+			body:          unknownField,
+			expStatusCode: http.StatusOK,
+		},
+		"unknown field rejected when strict": {
+			limits:        strict,
+			body:          unknownField,
+			expStatusCode: http.StatusBadRequest,
+		},
+		"trailing data": {
+			limits:        limits,
+			body:          validReceipt + `{"retailer": "Target"}`,
+			expStatusCode: http.StatusBadRequest,
+		},
+		"trailing garbage": {
+			limits:        limits,
+			body:          validReceipt + "}",
+			expStatusCode: http.StatusBadRequest,
+		},
+		"malformed json": {
+			limits:        limits,
+			body:          `{"retailer": `,
+			expStatusCode: http.StatusBadRequest,
+		},
+		"empty body": {
+			limits:        limits,
+			expStatusCode: http.StatusBadRequest,
+		},
+		"too many items": {
+			limits:        limits,
+			body:          manyItems,
+			expStatusCode: http.StatusBadRequest,
+		},
+		"retailer too long": {
+			limits:        limits,
+			body:          longRetailer,
+			expStatusCode: http.StatusBadRequest,
+		},
+		"item description too long": {
+			limits:        limits,
+			body:          longDescription,
+			expStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			c := New(repositories.New(), WithRequestLimits(tc.limits))
+			r := mux.NewRouter()
+			c.Register(r)
+
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, tc.body, nil, nil)
+			if status != tc.expStatusCode {
+				t.Errorf("unexpected status code: got %d, want %d", status, tc.expStatusCode)
+			}
+		})
+	}
+}
+
+func Test_ReviewReceipt_emptyBody(t *testing.T) {
+	c := New(repositories.New(), WithInsecureAdmin())
+	r := mux.NewRouter()
+	c.Register(r)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	// An empty body is a review without a note, so the receipt is looked up
+	// and not found rather than the body being rejected.
+	status := doJSON(t, http.MethodPost, srv.URL+fmt.Sprintf(endpointApprove, "5f6a9f42-7d7c-4c6e-9d52-8e2f1f0b3c11"), "", nil, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("unexpected status code: got %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func Test_ProcessReceipt_metrics(t *testing.T) {
+	m := metrics.New()
+	c := New(repositories.New(), WithMetrics(m))
+	r := mux.NewRouter()
+	c.Register(r)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, nil, nil)
+	doJSON(t, http.MethodPost, srv.URL+endpointProcess, invalidReceiptNoRetailer, nil, nil)
+	doJSON(t, http.MethodPost, srv.URL+endpointProcess, `{"retailer": `, nil, nil)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, expected := range []string{
+		`receipts_processed_total{status="accepted"} 1`,
+		`receipts_points_awarded_sum 28`,
+		`receipts_validation_failures_total{reason="invalid_retailer"} 1`,
+		`receipts_validation_failures_total{reason="malformed_json"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("metrics do not contain %q", expected)
+		}
+	}
+}
+
+func Test_timedOutRequests(t *testing.T) {
+	repo := repositories.New()
+	c := New(repo)
+	r := mux.NewRouter()
+	c.Register(r)
+	// Every request arrives with its deadline already passed.
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx, cancel := context.WithDeadline(req.Context(), time.Now().Add(-time.Second))
+			defer cancel()
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	testCases := map[string]struct {
+		method string
+		url    string
+		body   string
+	}{
+		"process receipt": {
+			method: http.MethodPost,
+			url:    endpointProcess,
+			body:   validReceipt,
+		},
+		"get points": {
+			method: http.MethodGet,
+			url:    fmt.Sprintf(endpointGetPoints, "5f6a9f42-7d7c-4c6e-9d52-8e2f1f0b3c11"),
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			status := doJSON(t, tc.method, srv.URL+tc.url, tc.body, nil, nil)
+			if status != http.StatusServiceUnavailable {
+				t.Errorf("unexpected status code: got %d, want %d", status, http.StatusServiceUnavailable)
+			}
+		})
+	}
+	if size := repo.Size(); size.Receipts != 0 {
+		t.Errorf("receipt stored after the deadline: %d receipts", size.Receipts)
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+// ListReviewReceipts lists receipts with the status query parameter,
+// defaulting to those pending review.
+func (c *controller) ListReviewReceipts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		status := r.URL.Query().Get("status")
+		switch status {
+		case "":
+			status = entities.ReceiptStatusPending
+		case entities.ReceiptStatusPending, entities.ReceiptStatusApproved, entities.ReceiptStatusRejected:
+		default:
+			c.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(errFmtInvalidStatus, status))
+			return
+		}
+
+		records, err := tenant.repository.ListReceiptsByStatus(status)
+		if err != nil {
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtListReceipts, err.Error()))
+			return
+		}
+
+		items := make([]entities.ReviewItem, 0, len(records))
+		for _, record := range records {
+			items = append(items, entities.NewReviewItem(record))
+		}
+		c.writeResponse(w, r, entities.ReviewListResponse{Receipts: items})
+	}
+}
+
+// ReviewReceipt approves or rejects a receipt pending review.
+func (c *controller) ReviewReceipt(approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		receiptID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+		logging.With(r.Context(), "receipt_id", receiptID.String())
+
+		var req entities.ReviewRequest
+		if !c.decodeBody(w, r, &req, true) {
+			return
+		}
+
+		var reviewer string
+		if id, ok := auth.FromContext(r.Context()); ok {
+			reviewer = id.ClientID
+		}
+
+		record, err := tenant.repository.ReviewReceipt(receiptID, approve, reviewer, req.Note)
+		switch err {
+		case nil:
+		case repositories.ErrNotFound:
+			c.writeError(w, r, http.StatusNotFound, errNoReceiptFound)
+			return
+		case repositories.ErrNotPending:
+			c.writeError(w, r, http.StatusConflict, errMsgNotPending)
+			return
+		default:
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtReviewReceipt, receiptID.String(), err.Error()))
+			return
+		}
+
+		c.writeResponse(w, r, entities.NewReviewItem(*record))
+	}
+}
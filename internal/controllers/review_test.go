@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+const (
+	endpointAdminReceipts = "/admin/receipts"
+	endpointApprove       = "/admin/receipts/%s/approve"
+	endpointReject        = "/admin/receipts/%s/reject"
+)
+
+func Test_ReviewReceipt(t *testing.T) {
+	m := repositories.New()
+	c := New(m, WithInsecureAdmin())
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(integrationAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var user entities.CreateUserResponse
+	doJSON(t, http.MethodPost, srv.URL+endpointUsers, `{"name":"Ada"}`, nil, &user)
+
+	futureReceipt := strings.Replace(validReceipt, "2022-01-01", "2999-01-01", 1)
+	var held [2]entities.ProcessResponse
+	for i := range held {
+		doJSON(t, http.MethodPost, srv.URL+endpointProcess, futureReceipt, map[string]string{headerUserID: user.ID}, &held[i])
+	}
+	doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, nil, nil)
+
+	var pending entities.ReviewListResponse
+	doJSON(t, http.MethodGet, srv.URL+endpointAdminReceipts, "", nil, &pending)
+	if len(pending.Receipts) != 2 {
+		t.Fatalf("unexpected pending count: got %d, want %d", len(pending.Receipts), 2)
+	}
+	if pending.Receipts[0].ID != held[0].ID || len(pending.Receipts[0].RiskReasons) == 0 {
+		t.Errorf("unexpected first pending receipt: %+v", pending.Receipts[0])
+	}
+
+	testCases := []struct {
+		name          string
+		endpoint      string
+		receiptID     string
+		expStatusCode int
+	}{
+		{
+			name:          "approve",
+			endpoint:      endpointApprove,
+			receiptID:     held[0].ID,
+			expStatusCode: http.StatusOK,
+		},
+		{
+			name:          "reject",
+			endpoint:      endpointReject,
+			receiptID:     held[1].ID,
+			expStatusCode: http.StatusOK,
+		},
+		{
+			name:          "already reviewed",
+			endpoint:      endpointApprove,
+			receiptID:     held[1].ID,
+			expStatusCode: http.StatusConflict,
+		},
+		{
+			name:          "unknown receipt",
+			endpoint:      endpointApprove,
+			receiptID:     uuid.New().String(),
+			expStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status := doJSON(t, http.MethodPost, srv.URL+fmt.Sprintf(tc.endpoint, tc.receiptID), `{"note":"checked"}`, nil, nil)
+			if status != tc.expStatusCode {
+				t.Errorf("unexpected status code: got %d, want %d", status, tc.expStatusCode)
+			}
+		})
+	}
+
+	var approved, rejected entities.PointsResponse
+	doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointGetPoints, held[0].ID), "", nil, &approved)
+	if approved.Points != 28 || approved.Status != "" {
+		t.Errorf("unexpected points response for approved receipt: %+v", approved)
+	}
+	doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointGetPoints, held[1].ID), "", nil, &rejected)
+	if rejected.Points != 0 || rejected.Status != entities.ReceiptStatusRejected {
+		t.Errorf("unexpected points response for rejected receipt: %+v", rejected)
+	}
+
+	var balance entities.BalanceResponse
+	doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserBalance, user.ID), "", nil, &balance)
+	if balance.Balance != 28 {
+		t.Errorf("unexpected balance: got %d, want %d", balance.Balance, 28)
+	}
+
+	doJSON(t, http.MethodGet, srv.URL+endpointAdminReceipts, "", nil, &pending)
+	if len(pending.Receipts) != 0 {
+		t.Errorf("unexpected pending count after review: got %d, want %d", len(pending.Receipts), 0)
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+endpointAdminReceipts+"?status=lost", "", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("unexpected status code for unknown status: got %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func Test_Register_authorization(t *testing.T) {
+	m := repositories.New()
+	c := New(m, WithAuthorization())
+
+	keys, err := auth.NewAPIKeys([]auth.APIKey{
+		{ClientID: "partner-a", Hash: auth.HashKey("partner-secret")},
+		{ClientID: "ops", Hash: auth.HashKey("ops-secret"), Scopes: []string{auth.ScopeAdmin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(keys))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var receipt entities.ProcessResponse
+	status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, map[string]string{auth.HeaderAPIKey: "partner-secret"}, &receipt)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status code processing a receipt: got %d, want %d", status, http.StatusOK)
+	}
+
+	testCases := map[string]struct {
+		method   string
+		endpoint string
+		body     string
+	}{
+		"list review queue": {
+			method:   http.MethodGet,
+			endpoint: endpointAdminReceipts,
+		},
+		"void receipt": {
+			method:   http.MethodPost,
+			endpoint: fmt.Sprintf(endpointVoid, receipt.ID),
+			body:     `{"reason":"duplicate"}`,
+		},
+		"create reward": {
+			method:   http.MethodPost,
+			endpoint: endpointRewards,
+			body:     `{"name":"Mug","cost":100,"inventory":1}`,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			status := doJSON(t, tc.method, srv.URL+tc.endpoint, tc.body, map[string]string{auth.HeaderAPIKey: "partner-secret"}, nil)
+			if status != http.StatusForbidden {
+				t.Errorf("unexpected status code without scope: got %d, want %d", status, http.StatusForbidden)
+			}
+			status = doJSON(t, tc.method, srv.URL+tc.endpoint, tc.body, map[string]string{auth.HeaderAPIKey: "ops-secret"}, nil)
+			if status != http.StatusOK {
+				t.Errorf("unexpected status code with admin scope: got %d, want %d", status, http.StatusOK)
+			}
+		})
+	}
+}
+
+func Test_Register_adminDisabled(t *testing.T) {
+	m := repositories.New()
+	c := New(m)
+
+	r := mux.NewRouter()
+	c.Register(r)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var receipt entities.ProcessResponse
+	if status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, nil, &receipt); status != http.StatusOK {
+		t.Fatalf("unexpected status code processing a receipt: got %d, want %d", status, http.StatusOK)
+	}
+
+	testCases := map[string]struct {
+		method   string
+		endpoint string
+		body     string
+	}{
+		"list review queue": {
+			method:   http.MethodGet,
+			endpoint: endpointAdminReceipts,
+		},
+		"approve receipt": {
+			method:   http.MethodPost,
+			endpoint: fmt.Sprintf(endpointApprove, receipt.ID),
+		},
+		"void receipt": {
+			method:   http.MethodPost,
+			endpoint: fmt.Sprintf(endpointVoid, receipt.ID),
+			body:     `{"reason":"duplicate"}`,
+		},
+		"create reward": {
+			method:   http.MethodPost,
+			endpoint: endpointRewards,
+			body:     `{"name":"Mug","cost":100,"inventory":1}`,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			if status := doJSON(t, tc.method, srv.URL+tc.endpoint, tc.body, nil, nil); status != http.StatusForbidden {
+				t.Errorf("unexpected status code without authentication: got %d, want %d", status, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+func (c *controller) CreateReward() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		var reward entities.Reward
+		if !c.decodeBody(w, r, &reward, false) {
+			return
+		}
+
+		if !reward.Validate() {
+			c.writeError(w, r, http.StatusBadRequest, errMsgInvalidReward)
+			return
+		}
+
+		var err error
+		reward.ID, err = tenant.repository.CreateReward(reward)
+		if err != nil {
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtCreateReward, err.Error()))
+			return
+		}
+		c.writeResponse(w, r, reward)
+	}
+}
+
+func (c *controller) ListRewards() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		rewards, err := tenant.repository.ListRewards()
+		if err != nil {
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtRewardReadError, err.Error()))
+			return
+		}
+		c.writeResponse(w, r, entities.RewardsResponse{Rewards: rewards})
+	}
+}
+
+func (c *controller) GetReward() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		rewardID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		reward, err := tenant.repository.GetReward(rewardID)
+		if err != nil {
+			c.writeRewardsError(w, r, err, errNoRewardFound)
+			return
+		}
+		c.writeResponse(w, r, reward)
+	}
+}
+
+func (c *controller) RedeemReward() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+		if !c.authorizeUser(w, r, tenant.repository, userID) {
+			return
+		}
+
+		var req entities.RedeemRequest
+		if !c.decodeBody(w, r, &req, false) {
+			return
+		}
+
+		rewardID, err := uuid.Parse(req.RewardID)
+		if err != nil {
+			c.writeError(w, r, http.StatusBadRequest, errMsgInvalidReward)
+			return
+		}
+
+		redemption, err := tenant.repository.Redeem(userID, rewardID)
+		if err != nil {
+			c.writeRewardsError(w, r, err, errNoUserOrRewardFound)
+			return
+		}
+		c.writeResponse(w, r, redemption)
+	}
+}
+
+func (c *controller) GetRedemption() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		redemptionID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		redemption, ok := c.redemptionFor(w, r, tenant.repository, redemptionID)
+		if !ok {
+			return
+		}
+		c.writeResponse(w, r, redemption)
+	}
+}
+
+func (c *controller) CancelRedemption() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		redemptionID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		if _, ok := c.redemptionFor(w, r, tenant.repository, redemptionID); !ok {
+			return
+		}
+
+		redemption, err := tenant.repository.CancelRedemption(redemptionID)
+		if err != nil {
+			c.writeRewardsError(w, r, err, errNoRedemptionFound)
+			return
+		}
+		c.writeResponse(w, r, redemption)
+	}
+}
+
+// redemptionFor reads a redemption the caller may act on, writing an error
+// response if it cannot.
+func (c *controller) redemptionFor(w http.ResponseWriter, r *http.Request, repository repositories.Repository, redemptionID uuid.UUID) (*entities.Redemption, bool) {
+	redemption, err := repository.GetRedemption(redemptionID)
+	if err != nil {
+		c.writeRewardsError(w, r, err, errNoRedemptionFound)
+		return nil, false
+	}
+	userID, err := uuid.Parse(redemption.UserID)
+	if err != nil {
+		c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtRedeem, err.Error()))
+		return nil, false
+	}
+	if !c.authorizeUser(w, r, repository, userID) {
+		return nil, false
+	}
+	return redemption, true
+}
+
+// writeRewardsError maps rewards repository errors to a response, using
+// notFoundMsg when the requested entity does not exist.
+func (c *controller) writeRewardsError(w http.ResponseWriter, r *http.Request, err error, notFoundMsg string) {
+	switch err {
+	case repositories.ErrNotFound:
+		c.writeError(w, r, http.StatusNotFound, notFoundMsg)
+	case repositories.ErrInsufficientPoints, repositories.ErrOutOfStock, repositories.ErrAlreadyCancelled:
+		c.writeError(w, r, http.StatusConflict, fmt.Sprintf(errFmtRedeem, err.Error()))
+	default:
+		c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtRedeem, err.Error()))
+	}
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+const (
+	endpointRewards          = "/rewards"
+	endpointUserRedemptions  = "/users/%s/redemptions"
+	endpointCancelRedemption = "/redemptions/%s/cancel"
+)
+
+func Test_RedeemReward(t *testing.T) {
+	m := repositories.New()
+	c := New(m, WithInsecureAdmin())
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(integrationAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var user entities.CreateUserResponse
+	doJSON(t, http.MethodPost, srv.URL+endpointUsers, `{"name":"Ada"}`, nil, &user)
+	doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, map[string]string{headerUserID: user.ID}, nil)
+
+	var cheap, expensive entities.Reward
+	doJSON(t, http.MethodPost, srv.URL+endpointRewards, `{"name":"Sticker","cost":20,"inventory":5}`, nil, &cheap)
+	doJSON(t, http.MethodPost, srv.URL+endpointRewards, `{"name":"Tote Bag","cost":500,"inventory":5}`, nil, &expensive)
+	if status := doJSON(t, http.MethodPost, srv.URL+endpointRewards, `{"name":"Free","cost":0}`, nil, nil); status != http.StatusBadRequest {
+		t.Errorf("unexpected status code creating invalid reward: got %d, want %d", status, http.StatusBadRequest)
+	}
+
+	var catalog entities.RewardsResponse
+	doJSON(t, http.MethodGet, srv.URL+endpointRewards, "", nil, &catalog)
+	if len(catalog.Rewards) != 2 {
+		t.Fatalf("unexpected catalog size: got %d, want %d", len(catalog.Rewards), 2)
+	}
+
+	testCases := []struct {
+		name          string
+		userID        string
+		rewardID      string
+		expStatusCode int
+	}{
+		{
+			name:          "success",
+			userID:        user.ID,
+			rewardID:      cheap.ID,
+			expStatusCode: http.StatusOK,
+		},
+		{
+			name:          "insufficient points",
+			userID:        user.ID,
+			rewardID:      expensive.ID,
+			expStatusCode: http.StatusConflict,
+		},
+		{
+			name:          "unknown reward",
+			userID:        user.ID,
+			rewardID:      uuid.New().String(),
+			expStatusCode: http.StatusNotFound,
+		},
+		{
+			name:          "unknown user",
+			userID:        uuid.New().String(),
+			rewardID:      cheap.ID,
+			expStatusCode: http.StatusNotFound,
+		},
+		{
+			name:          "invalid reward id",
+			userID:        user.ID,
+			rewardID:      "sticker",
+			expStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	var redemption entities.Redemption
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"rewardId":%q}`, tc.rewardID)
+			status := doJSON(t, http.MethodPost, srv.URL+fmt.Sprintf(endpointUserRedemptions, tc.userID), body, nil, &redemption)
+			if status != tc.expStatusCode {
+				t.Errorf("unexpected status code: got %d, want %d", status, tc.expStatusCode)
+			}
+		})
+	}
+
+	var balance entities.BalanceResponse
+	doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserBalance, user.ID), "", nil, &balance)
+	if balance.Balance != 8 {
+		t.Errorf("unexpected balance after redemption: got %d, want %d", balance.Balance, 8)
+	}
+
+	cancelURL := srv.URL + fmt.Sprintf(endpointCancelRedemption, redemption.ID)
+	if status := doJSON(t, http.MethodPost, cancelURL, "", nil, nil); status != http.StatusOK {
+		t.Errorf("unexpected status code cancelling: got %d, want %d", status, http.StatusOK)
+	}
+	if status := doJSON(t, http.MethodPost, cancelURL, "", nil, nil); status != http.StatusConflict {
+		t.Errorf("unexpected status code cancelling twice: got %d, want %d", status, http.StatusConflict)
+	}
+
+	doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserBalance, user.ID), "", nil, &balance)
+	if balance.Balance != 28 {
+		t.Errorf("unexpected balance after cancellation: got %d, want %d", balance.Balance, 28)
+	}
+}
+
+func Test_UserRoutes_otherUser(t *testing.T) {
+	m := repositories.New()
+	c := New(m, WithAuthorization())
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(subjectAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	as := func(subject string) map[string]string {
+		return map[string]string{"Authorization": subject}
+	}
+	bob, err := m.UserForSubject("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, as("bob"), nil)
+	var reward entities.Reward
+	doJSON(t, http.MethodPost, srv.URL+endpointRewards, `{"name":"Sticker","cost":20,"inventory":5}`, as("admin"), &reward)
+	var redemption entities.Redemption
+	redeemBody := fmt.Sprintf(`{"rewardId":%q}`, reward.ID)
+	if status := doJSON(t, http.MethodPost, srv.URL+fmt.Sprintf(endpointUserRedemptions, bob.ID), redeemBody, as("bob"), &redemption); status != http.StatusOK {
+		t.Fatalf("unexpected status code redeeming for self: got %d, want %d", status, http.StatusOK)
+	}
+
+	testCases := map[string]struct {
+		method string
+		url    string
+		body   string
+	}{
+		"balance":           {method: http.MethodGet, url: fmt.Sprintf(endpointUserBalance, bob.ID)},
+		"ledger":            {method: http.MethodGet, url: fmt.Sprintf(endpointUserLedger, bob.ID)},
+		"expiring":          {method: http.MethodGet, url: fmt.Sprintf(endpointUserExpires, bob.ID)},
+		"tier":              {method: http.MethodGet, url: fmt.Sprintf(endpointUserTier, bob.ID)},
+		"redeem":            {method: http.MethodPost, url: fmt.Sprintf(endpointUserRedemptions, bob.ID), body: redeemBody},
+		"get redemption":    {method: http.MethodGet, url: "/redemptions/" + redemption.ID},
+		"cancel redemption": {method: http.MethodPost, url: fmt.Sprintf(endpointCancelRedemption, redemption.ID)},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			if status := doJSON(t, tc.method, srv.URL+tc.url, tc.body, as("alice"), nil); status != http.StatusForbidden {
+				t.Errorf("unexpected status code for another user: got %d, want %d", status, http.StatusForbidden)
+			}
+			if tc.method == http.MethodGet {
+				if status := doJSON(t, tc.method, srv.URL+tc.url, tc.body, as("admin"), nil); status != http.StatusOK {
+					t.Errorf("unexpected status code for an admin: got %d, want %d", status, http.StatusOK)
+				}
+			}
+		})
+	}
+
+	var balance entities.BalanceResponse
+	doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserBalance, bob.ID), "", as("bob"), &balance)
+	if balance.Balance != 8 {
+		t.Errorf("another user changed the balance: got %d, want %d", balance.Balance, 8)
+	}
+	if status := doJSON(t, http.MethodPost, srv.URL+fmt.Sprintf(endpointCancelRedemption, redemption.ID), "", as("bob"), nil); status != http.StatusOK {
+		t.Errorf("unexpected status code cancelling own redemption: got %d, want %d", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"github.com/gpayne44/fetch-challenge/internal/tracing"
+)
+
+// tenant is the storage and scoring a loyalty program's requests are served
+// from.
+type tenant struct {
+	// id is empty when the service hosts a single program.
+	id         string
+	repository repositories.Repository
+	ruleset    *process.Ruleset
+}
+
+// WithTenant serves requests for the tenant id from their own repository,
+// scored with rs, or the controller's ruleset if rs is nil. Once any tenant
+// is added every request must belong to one, and the repository passed to
+// New is no longer used and may be nil.
+func WithTenant(id string, repository repositories.Repository, rs *process.Ruleset) Option {
+	return func(c *controller) {
+		if c.tenants == nil {
+			c.tenants = make(map[string]tenant)
+		}
+		c.tenants[id] = tenant{id: id, repository: repository, ruleset: rs}
+	}
+}
+
+// tenantFor returns the tenant the request belongs to, writing an error
+// response if it cannot be served. A tenant named by the caller's
+// credentials cannot be overridden by the header, and credentials tied to
+// no tenant may only use the header with auth.ScopeAnyTenant. Requests
+// without credentials reach the controller only when authentication is
+// off, and are trusted to name their tenant.
+func (c *controller) tenantFor(w http.ResponseWriter, r *http.Request) (tenant, bool) {
+	t := tenant{repository: c.repository, ruleset: c.ruleset}
+	if c.tenants != nil {
+		tenantID := r.Header.Get(auth.HeaderTenantID)
+		identity, authenticated := auth.FromContext(r.Context())
+		switch {
+		case authenticated && identity.Tenant != "":
+			if tenantID != "" && tenantID != identity.Tenant {
+				c.writeError(w, r, http.StatusForbidden, fmt.Sprintf(errFmtTenantMismatch, tenantID))
+				return tenant{}, false
+			}
+			tenantID = identity.Tenant
+		case authenticated && !identity.HasScope(auth.ScopeAnyTenant):
+			c.writeError(w, r, http.StatusForbidden, errMsgTenantRequired)
+			return tenant{}, false
+		}
+
+		var ok bool
+		t, ok = c.tenants[tenantID]
+		if !ok {
+			c.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(errFmtUnknownTenant, tenantID))
+			return tenant{}, false
+		}
+		if t.ruleset == nil {
+			t.ruleset = c.ruleset
+		}
+	}
+
+	t.repository = repositories.WithContext(r.Context(), t.repository)
+	if c.trace {
+		t.repository = repositories.WithObserver(t.repository, tracing.Observer(r.Context()))
+	}
+	return t, true
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"github.com/gpayne44/fetch-challenge/internal/risk"
+)
+
+func Test_Tenants(t *testing.T) {
+	lunch := &process.Ruleset{TimeWindows: []process.TimeWindow{{
+		Name:           "lunch",
+		Start:          process.ClockTime(13 * 60),
+		End:            process.ClockTime(14 * 60),
+		StartInclusive: true,
+		Points:         50,
+	}}}
+	c := New(repositories.New(),
+		WithTenant("acme", repositories.New(), nil),
+		WithTenant("brunch-club", repositories.New(), lunch),
+	)
+
+	keys, err := auth.NewAPIKeys([]auth.APIKey{
+		{ClientID: "acme-app", Hash: auth.HashKey("acme-secret"), Tenant: "acme"},
+		{ClientID: "partner", Hash: auth.HashKey("partner-secret"), Scopes: []string{auth.ScopeAnyTenant}},
+		{ClientID: "legacy", Hash: auth.HashKey("legacy-secret")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(keys))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	submit := func(headers map[string]string) (string, int) {
+		var res entities.ProcessResponse
+		status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, headers, &res)
+		return res.ID, status
+	}
+	points := func(id string, headers map[string]string) (int, int) {
+		var res entities.PointsResponse
+		status := doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointGetPoints, id), "", headers, &res)
+		return res.Points, status
+	}
+
+	acmeID, status := submit(map[string]string{auth.HeaderAPIKey: "acme-secret"})
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d, want %d", status, http.StatusOK)
+	}
+	brunchID, status := submit(map[string]string{auth.HeaderAPIKey: "partner-secret", auth.HeaderTenantID: "brunch-club"})
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d, want %d", status, http.StatusOK)
+	}
+
+	testCases := map[string]struct {
+		id            string
+		headers       map[string]string
+		expStatusCode int
+		expPoints     int
+	}{
+		"tenant from api key": {
+			id:            acmeID,
+			headers:       map[string]string{auth.HeaderAPIKey: "acme-secret"},
+			expStatusCode: http.StatusOK,
+			expPoints:     28,
+		},
+		"tenant ruleset": {
+			id:            brunchID,
+			headers:       map[string]string{auth.HeaderAPIKey: "partner-secret", auth.HeaderTenantID: "brunch-club"},
+			expStatusCode: http.StatusOK,
+			expPoints:     78,
+		},
+		"other tenant's receipt is not found": {
+			id:            brunchID,
+			headers:       map[string]string{auth.HeaderAPIKey: "acme-secret"},
+			expStatusCode: http.StatusNotFound,
+		},
+		"header cannot override api key tenant": {
+			id:            brunchID,
+			headers:       map[string]string{auth.HeaderAPIKey: "acme-secret", auth.HeaderTenantID: "brunch-club"},
+			expStatusCode: http.StatusForbidden,
+		},
+		"unknown tenant": {
+			id:            acmeID,
+			headers:       map[string]string{auth.HeaderAPIKey: "partner-secret", auth.HeaderTenantID: "globex"},
+			expStatusCode: http.StatusBadRequest,
+		},
+		"no tenant": {
+			id:            acmeID,
+			headers:       map[string]string{auth.HeaderAPIKey: "partner-secret"},
+			expStatusCode: http.StatusBadRequest,
+		},
+		"tenantless key without scope cannot name a tenant": {
+			id:            acmeID,
+			headers:       map[string]string{auth.HeaderAPIKey: "legacy-secret", auth.HeaderTenantID: "acme"},
+			expStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			got, status := points(tc.id, tc.headers)
+			if status != tc.expStatusCode {
+				t.Fatalf("unexpected status code: got %d, want %d", status, tc.expStatusCode)
+			}
+			if got != tc.expPoints {
+				t.Errorf("unexpected points: got %d, want %d", got, tc.expPoints)
+			}
+		})
+	}
+}
+
+func Test_Tenants_riskHistory(t *testing.T) {
+	c := New(repositories.New(),
+		WithTenant("acme", repositories.New(), nil),
+		WithTenant("brunch-club", repositories.New(), nil),
+		WithRiskEvaluator(risk.New(1, risk.NewBurstCheck(1, time.Minute))),
+	)
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(anyTenantAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for i, tc := range []struct {
+		tenant         string
+		expectedStatus string
+	}{
+		{tenant: "acme"},
+		{tenant: "brunch-club"},
+		{tenant: "acme", expectedStatus: entities.ReceiptStatusPending},
+	} {
+		var res entities.ProcessResponse
+		status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, map[string]string{auth.HeaderTenantID: tc.tenant}, &res)
+		if status != http.StatusOK {
+			t.Fatalf("receipt %d: unexpected status code: got %d, want %d", i, status, http.StatusOK)
+		}
+		if res.Status != tc.expectedStatus {
+			t.Errorf("receipt %d for %s: unexpected status: got %q, want %q", i, tc.tenant, res.Status, tc.expectedStatus)
+		}
+	}
+}
+
+// anyTenantAuthenticator identifies every request as an integration that
+// may act for any tenant.
+type anyTenantAuthenticator struct{}
+
+func (anyTenantAuthenticator) Authenticate(r *http.Request) (auth.Identity, error) {
+	return auth.Identity{ClientID: "partner-a", Scopes: []string{auth.ScopeAnyTenant}}, nil
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"github.com/gpayne44/fetch-challenge/internal/tiers"
+)
+
+func (c *controller) CreateUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		var req entities.CreateUserRequest
+		if !c.decodeBody(w, r, &req, false) {
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" {
+			c.writeError(w, r, http.StatusBadRequest, errMsgInvalidUser)
+			return
+		}
+
+		newID, err := tenant.repository.CreateUser(entities.User{Name: req.Name})
+		if err != nil {
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtCreateUser, err.Error()))
+			return
+		}
+
+		resBytes, err := json.Marshal(entities.CreateUserResponse{ID: newID})
+		if err != nil {
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtMarshalIDResponse, err.Error()))
+			return
+		}
+		w.Write(resBytes)
+	}
+}
+
+func (c *controller) GetUserBalance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+		if !c.authorizeUser(w, r, tenant.repository, userID) {
+			return
+		}
+
+		balance, err := tenant.repository.GetBalance(userID)
+		if err != nil {
+			c.writeUserReadError(w, r, userID, err)
+			return
+		}
+
+		c.writeResponse(w, r, entities.BalanceResponse{UserID: userID.String(), Balance: balance})
+	}
+}
+
+func (c *controller) GetUserLedger() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+		if !c.authorizeUser(w, r, tenant.repository, userID) {
+			return
+		}
+
+		entries, err := tenant.repository.GetLedger(userID)
+		if err != nil {
+			c.writeUserReadError(w, r, userID, err)
+			return
+		}
+
+		c.writeResponse(w, r, entities.LedgerResponse{UserID: userID.String(), Entries: entries})
+	}
+}
+
+// defaultExpiringDays is how far ahead GetExpiringPoints looks when the
+// request has no days parameter.
+const defaultExpiringDays = 30
+
+func (c *controller) GetExpiringPoints() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+		if !c.authorizeUser(w, r, tenant.repository, userID) {
+			return
+		}
+
+		days := defaultExpiringDays
+		if daysParam := r.URL.Query().Get("days"); daysParam != "" {
+			parsed, err := strconv.Atoi(daysParam)
+			if err != nil || parsed < 0 {
+				c.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(errFmtInvalidDays, daysParam))
+				return
+			}
+			days = parsed
+		}
+
+		entries, err := tenant.repository.GetLedger(userID)
+		if err != nil {
+			c.writeUserReadError(w, r, userID, err)
+			return
+		}
+
+		deadline := c.now().AddDate(0, 0, days)
+		points, buckets := c.expiration.Expiring(entries, deadline)
+		if buckets == nil {
+			buckets = []entities.ExpiringBucket{}
+		}
+		c.writeResponse(w, r, entities.ExpiringResponse{UserID: userID.String(), Points: points, Buckets: buckets})
+	}
+}
+
+func (c *controller) GetUserTier() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := c.tenantFor(w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := c.parseIDParam(w, r)
+		if !ok {
+			return
+		}
+		if !c.authorizeUser(w, r, tenant.repository, userID) {
+			return
+		}
+
+		if _, err := tenant.repository.GetUser(userID); err != nil {
+			c.writeUserReadError(w, r, userID, err)
+			return
+		}
+
+		tier, next, qualifying, err := c.userTier(tenant.repository, userID)
+		if err != nil {
+			c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtTierReadError, userID.String(), err.Error()))
+			return
+		}
+
+		res := entities.TierResponse{
+			UserID:            userID.String(),
+			Tier:              tier.Name,
+			MultiplierPercent: tier.MultiplierPercent,
+			QualifyingPoints:  qualifying,
+		}
+		if next != nil {
+			res.NextTier = next.Name
+			res.PointsToNextTier = next.Threshold - qualifying
+		}
+		c.writeResponse(w, r, res)
+	}
+}
+
+// userTier returns the user's current and next tier from the base points
+// of their receipts in the qualifying window.
+func (c *controller) userTier(repository repositories.Repository, userID uuid.UUID) (tiers.Tier, *tiers.Tier, int, error) {
+	records, err := repository.ListReceipts(userID)
+	if err != nil {
+		return tiers.Tier{}, nil, 0, err
+	}
+	qualifying := tiers.QualifyingPoints(records, c.now())
+	tier, next := c.tiers.For(qualifying)
+	return tier, next, qualifying, nil
+}
+
+// authorizeUser writes a forbidden response unless the caller may act for
+// the user. Callers signed in as a user may only act for themselves, while
+// trusted integrations, administrators and unauthenticated deployments may
+// act for anyone.
+func (c *controller) authorizeUser(w http.ResponseWriter, r *http.Request, repository repositories.Repository, userID uuid.UUID) bool {
+	identity, _ := auth.FromContext(r.Context())
+	if identity.Subject == "" || identity.HasScope(auth.ScopeAdmin) {
+		return true
+	}
+	user, err := repository.UserForSubject(identity.Subject)
+	if err != nil {
+		c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtSubjectReadError, identity.Subject, err.Error()))
+		return false
+	}
+	if user.ID != userID.String() {
+		c.writeError(w, r, http.StatusForbidden, errMsgOtherUser)
+		return false
+	}
+	return true
+}
+
+// parseIDParam reads the ID path parameter, writing a bad request response
+// if it is missing or invalid.
+func (c *controller) parseIDParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	idParam := mux.Vars(r)["id"]
+	if idParam == "" {
+		c.writeError(w, r, http.StatusBadRequest, errEmptyID)
+		return uuid.Nil, false
+	}
+
+	parsedID, err := uuid.Parse(idParam)
+	if err != nil {
+		c.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(errFmtInvalidReceiptID, idParam, err.Error()))
+		return uuid.Nil, false
+	}
+	return parsedID, true
+}
+
+// writeResponse marshals v as the body of a successful response.
+func (c *controller) writeResponse(w http.ResponseWriter, r *http.Request, v interface{}) {
+	resBytes, err := json.Marshal(v)
+	if err != nil {
+		c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtMarshalResponse, err.Error()))
+		return
+	}
+	w.Write(resBytes)
+}
+
+func (c *controller) writeUserReadError(w http.ResponseWriter, r *http.Request, userID uuid.UUID, err error) {
+	if err == repositories.ErrNotFound {
+		c.writeError(w, r, http.StatusNotFound, errNoUserFound)
+		return
+	}
+	c.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf(errFmtUserReadError, userID.String(), err.Error()))
+}
@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/expiration"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"github.com/gpayne44/fetch-challenge/internal/tiers"
+)
+
+const (
+	endpointUsers       = "/users"
+	endpointUserBalance = "/users/%s/balance"
+	endpointUserLedger  = "/users/%s/ledger"
+	endpointUserExpires = "/users/%s/expiring"
+	endpointUserTier    = "/users/%s/tier"
+)
+
+// doJSON sends a request with an optional JSON body and headers, decodes
+// the response body into out when it is non-nil and returns the status code.
+func doJSON(t *testing.T, method, url string, body string, headers map[string]string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error sending request: %s", err.Error())
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("error reading response body: %s", err.Error())
+	}
+	if out != nil && res.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(b, out); err != nil {
+			t.Fatalf("error unmarshal response body %q: %s", b, err.Error())
+		}
+	}
+	return res.StatusCode
+}
+
+// integrationAuthenticator signs every request in as a trusted
+// integration, which may credit receipts to any user with headerUserID.
+type integrationAuthenticator struct{}
+
+func (integrationAuthenticator) Authenticate(r *http.Request) (auth.Identity, error) {
+	return auth.Identity{ClientID: "partner-a"}, nil
+}
+
+func Test_UserLedger(t *testing.T) {
+	m := repositories.New()
+	c := New(m)
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(integrationAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var user entities.CreateUserResponse
+	if status := doJSON(t, http.MethodPost, srv.URL+endpointUsers, `{"name":"Ada"}`, nil, &user); status != http.StatusOK {
+		t.Fatalf("unexpected status code creating user: got %d, want %d", status, http.StatusOK)
+	}
+
+	testCases := map[string]struct {
+		userHeader    string
+		expStatusCode int
+	}{
+		"credited to user": {
+			userHeader:    user.ID,
+			expStatusCode: http.StatusOK,
+		},
+		"anonymous receipt": {
+			expStatusCode: http.StatusOK,
+		},
+		"unknown user": {
+			userHeader:    uuid.New().String(),
+			expStatusCode: http.StatusBadRequest,
+		},
+		"invalid user id": {
+			userHeader:    "not-a-uuid",
+			expStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			headers := map[string]string{headerUserID: tc.userHeader}
+			status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, headers, nil)
+			if status != tc.expStatusCode {
+				t.Errorf("unexpected status code: got %d, want %d", status, tc.expStatusCode)
+			}
+		})
+	}
+
+	var balance entities.BalanceResponse
+	if status := doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserBalance, user.ID), "", nil, &balance); status != http.StatusOK {
+		t.Fatalf("unexpected status code reading balance: got %d, want %d", status, http.StatusOK)
+	}
+	if balance.Balance != 28 {
+		t.Errorf("unexpected balance: got %d, want %d", balance.Balance, 28)
+	}
+
+	var ledger entities.LedgerResponse
+	if status := doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserLedger, user.ID), "", nil, &ledger); status != http.StatusOK {
+		t.Fatalf("unexpected status code reading ledger: got %d, want %d", status, http.StatusOK)
+	}
+	if len(ledger.Entries) != 1 {
+		t.Fatalf("unexpected ledger entry count: got %d, want %d", len(ledger.Entries), 1)
+	}
+	entry := ledger.Entries[0]
+	if entry.Type != entities.LedgerEntryCredit || entry.Points != 28 || entry.ReceiptID == "" {
+		t.Errorf("unexpected ledger entry: %+v", entry)
+	}
+
+	for _, endpoint := range []string{endpointUserBalance, endpointUserLedger} {
+		status := doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpoint, uuid.New().String()), "", nil, nil)
+		if status != http.StatusNotFound {
+			t.Errorf("unexpected status code for unknown user: got %d, want %d", status, http.StatusNotFound)
+		}
+	}
+}
+
+func Test_ProcessReceipt_untrustedUserHeader(t *testing.T) {
+	m := repositories.New()
+	c := New(m)
+
+	r := mux.NewRouter()
+	c.Register(r)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	userID, err := m.CreateUser(entities.User{Name: "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, map[string]string{headerUserID: userID}, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("unexpected status code: got %d, want %d", status, http.StatusForbidden)
+	}
+	balance, err := m.GetBalance(uuid.MustParse(userID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != 0 {
+		t.Errorf("unexpected balance: got %d, want %d", balance, 0)
+	}
+}
+
+func Test_CreateUser(t *testing.T) {
+	c := New(repositories.New())
+
+	r := mux.NewRouter()
+	c.Register(r)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	testCases := map[string]struct {
+		body          string
+		expStatusCode int
+	}{
+		"valid name": {
+			body:          `{"name":"Ada"}`,
+			expStatusCode: http.StatusOK,
+		},
+		"empty name": {
+			body:          `{"name":""}`,
+			expStatusCode: http.StatusBadRequest,
+		},
+		"whitespace name": {
+			body:          `{"name":" \t "}`,
+			expStatusCode: http.StatusBadRequest,
+		},
+		"no name": {
+			body:          `{}`,
+			expStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			status := doJSON(t, http.MethodPost, srv.URL+endpointUsers, tc.body, nil, nil)
+			if status != tc.expStatusCode {
+				t.Errorf("unexpected status code: got %d, want %d", status, tc.expStatusCode)
+			}
+		})
+	}
+}
+
+func Test_GetExpiringPoints(t *testing.T) {
+	m := repositories.New()
+	c := New(m, WithExpirationPolicy(expiration.Policy{AfterMonths: 12}))
+	c.now = func() time.Time { return time.Date(2022, 12, 15, 0, 0, 0, 0, time.UTC) }
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(integrationAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var user entities.CreateUserResponse
+	doJSON(t, http.MethodPost, srv.URL+endpointUsers, `{"name":"Ada"}`, nil, &user)
+	doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, map[string]string{headerUserID: user.ID}, nil)
+
+	testCases := map[string]struct {
+		query          string
+		expStatusCode  int
+		expectedPoints int
+	}{
+		"default window": {
+			expStatusCode:  http.StatusOK,
+			expectedPoints: 28,
+		},
+		"short window": {
+			query:         "?days=7",
+			expStatusCode: http.StatusOK,
+		},
+		"invalid days": {
+			query:         "?days=soon",
+			expStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			var expiring entities.ExpiringResponse
+			status := doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserExpires, user.ID)+tc.query, "", nil, &expiring)
+			if status != tc.expStatusCode {
+				t.Fatalf("unexpected status code: got %d, want %d", status, tc.expStatusCode)
+			}
+			if expiring.Points != tc.expectedPoints {
+				t.Errorf("unexpected expiring points: got %d, want %d", expiring.Points, tc.expectedPoints)
+			}
+		})
+	}
+}
+
+func Test_UserTier(t *testing.T) {
+	m := repositories.New()
+	c := New(m, WithTiers(tiers.Tiers{
+		{Name: "Bronze", Threshold: 0, MultiplierPercent: 100},
+		{Name: "Silver", Threshold: 20, MultiplierPercent: 125},
+		{Name: "Gold", Threshold: 100, MultiplierPercent: 150},
+	}))
+	c.now = func() time.Time { return time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(integrationAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var user entities.CreateUserResponse
+	doJSON(t, http.MethodPost, srv.URL+endpointUsers, `{"name":"Ada"}`, nil, &user)
+
+	testCases := []struct {
+		expectedTier       string
+		expectedQualifying int
+		expectedToNext     int
+		expectedBalance    int
+	}{
+		{expectedTier: "Bronze", expectedQualifying: 0, expectedToNext: 20, expectedBalance: 28},
+		{expectedTier: "Silver", expectedQualifying: 28, expectedToNext: 72, expectedBalance: 63},
+		{expectedTier: "Silver", expectedQualifying: 56, expectedToNext: 44, expectedBalance: 98},
+	}
+
+	for i, tc := range testCases {
+		var tier entities.TierResponse
+		if status := doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserTier, user.ID), "", nil, &tier); status != http.StatusOK {
+			t.Fatalf("unexpected status code: got %d, want %d", status, http.StatusOK)
+		}
+		if tier.Tier != tc.expectedTier || tier.QualifyingPoints != tc.expectedQualifying || tier.PointsToNextTier != tc.expectedToNext {
+			t.Errorf("receipt %d: unexpected tier: %+v", i, tier)
+		}
+
+		doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, map[string]string{headerUserID: user.ID}, nil)
+
+		var balance entities.BalanceResponse
+		doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserBalance, user.ID), "", nil, &balance)
+		if balance.Balance != tc.expectedBalance {
+			t.Errorf("receipt %d: unexpected balance: got %d, want %d", i, balance.Balance, tc.expectedBalance)
+		}
+	}
+
+	if status := doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserTier, uuid.New().String()), "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("unexpected status code for unknown user: got %d, want %d", status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/expiration"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+const endpointVoid = "/receipts/%s/void"
+
+func Test_VoidReceipt(t *testing.T) {
+	m := repositories.New()
+	c := New(m, WithInsecureAdmin())
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(integrationAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var user entities.CreateUserResponse
+	doJSON(t, http.MethodPost, srv.URL+endpointUsers, `{"name":"Ada"}`, nil, &user)
+	var receipt entities.ProcessResponse
+	doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, map[string]string{headerUserID: user.ID}, &receipt)
+
+	testCases := []struct {
+		name          string
+		receiptID     string
+		body          string
+		expStatusCode int
+	}{
+		{
+			name:          "missing reason",
+			receiptID:     receipt.ID,
+			body:          `{"reason":" "}`,
+			expStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:          "unknown receipt",
+			receiptID:     uuid.New().String(),
+			body:          `{"reason":"returned"}`,
+			expStatusCode: http.StatusNotFound,
+		},
+		{
+			name:          "success",
+			receiptID:     receipt.ID,
+			body:          `{"reason":"returned"}`,
+			expStatusCode: http.StatusOK,
+		},
+		{
+			name:          "already voided",
+			receiptID:     receipt.ID,
+			body:          `{"reason":"fraud"}`,
+			expStatusCode: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status := doJSON(t, http.MethodPost, srv.URL+fmt.Sprintf(endpointVoid, tc.receiptID), tc.body, nil, nil)
+			if status != tc.expStatusCode {
+				t.Errorf("unexpected status code: got %d, want %d", status, tc.expStatusCode)
+			}
+		})
+	}
+
+	var points entities.PointsResponse
+	doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointGetPoints, receipt.ID), "", nil, &points)
+	if points.Status != entities.ReceiptStatusVoided || points.Reason != "returned" || points.Points != 0 {
+		t.Errorf("unexpected points response for voided receipt: %+v", points)
+	}
+
+	var ledger entities.LedgerResponse
+	doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserLedger, user.ID), "", nil, &ledger)
+	if len(ledger.Entries) != 2 {
+		t.Fatalf("unexpected ledger entry count: got %d, want %d", len(ledger.Entries), 2)
+	}
+	reversal := ledger.Entries[1]
+	if reversal.Type != entities.LedgerEntryReversal || reversal.Points != -28 || reversal.ReceiptID != receipt.ID {
+		t.Errorf("unexpected reversal entry: %+v", reversal)
+	}
+
+	var balance entities.BalanceResponse
+	doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserBalance, user.ID), "", nil, &balance)
+	if balance.Balance != 0 {
+		t.Errorf("unexpected balance: got %d, want %d", balance.Balance, 0)
+	}
+}
+
+func Test_VoidReceipt_expiredPoints(t *testing.T) {
+	m := repositories.New()
+	c := New(m, WithInsecureAdmin(), WithExpirationPolicy(expiration.Policy{AfterMonths: 12}))
+
+	r := mux.NewRouter()
+	c.Register(r)
+	r.Use(auth.Middleware(integrationAuthenticator{}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	testCases := map[string]struct {
+		expired     int
+		expReversal int
+	}{
+		"all points expired": {
+			expired:     28,
+			expReversal: 0,
+		},
+		"some points expired": {
+			expired:     10,
+			expReversal: -18,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			var user entities.CreateUserResponse
+			doJSON(t, http.MethodPost, srv.URL+endpointUsers, `{"name":"Ada"}`, nil, &user)
+			var receipt entities.ProcessResponse
+			doJSON(t, http.MethodPost, srv.URL+endpointProcess, validReceipt, map[string]string{headerUserID: user.ID}, &receipt)
+			if _, err := m.ExpirePoints(uuid.MustParse(user.ID), func([]entities.LedgerEntry) int { return tc.expired }); err != nil {
+				t.Fatal(err)
+			}
+
+			if status := doJSON(t, http.MethodPost, srv.URL+fmt.Sprintf(endpointVoid, receipt.ID), `{"reason":"returned"}`, nil, nil); status != http.StatusOK {
+				t.Fatalf("unexpected status code: got %d, want %d", status, http.StatusOK)
+			}
+
+			var ledger entities.LedgerResponse
+			doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserLedger, user.ID), "", nil, &ledger)
+			var reversed int
+			for _, entry := range ledger.Entries {
+				if entry.Type == entities.LedgerEntryReversal {
+					reversed += entry.Points
+				}
+			}
+			if reversed != tc.expReversal {
+				t.Errorf("unexpected reversal: got %d, want %d", reversed, tc.expReversal)
+			}
+
+			var balance entities.BalanceResponse
+			doJSON(t, http.MethodGet, srv.URL+fmt.Sprintf(endpointUserBalance, user.ID), "", nil, &balance)
+			if balance.Balance != 0 {
+				t.Errorf("unexpected balance: got %d, want %d", balance.Balance, 0)
+			}
+		})
+	}
+}
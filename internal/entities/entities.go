@@ -1,27 +1,133 @@
 package entities
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	dateFmt     = "2006-01-02"
+	timeFmt     = "15:04"
+	dateTimeFmt = dateFmt + " " + timeFmt
+	offsetFmt   = "-07:00"
+)
+
+// amountPattern is the amount format from the receipt processor API
+// specification, limited to twelve integer digits so point calculations
+// cannot overflow.
+var amountPattern = regexp.MustCompile(`^\d{1,12}\.\d{2}$`)
+
 type Receipt struct {
 	Retailer     string `json:"retailer"`
 	PurchaseDate string `json:"purchaseDate"`
 	PurchaseTime string `json:"purchaseTime"`
 	Items        []Item `json:"items"`
 	Total        string `json:"total"`
+	// Timezone is the store's IANA time zone name, such as
+	// "America/Chicago", or a fixed UTC offset such as "-05:00". Receipts
+	// without a timezone are treated as UTC.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// CheckSize returns an error if the receipt lists more than maxItems items
+// or has a free text field longer than maxStringLength bytes. Limits of 0
+// are not enforced.
+func (r *Receipt) CheckSize(maxItems, maxStringLength int) error {
+	if maxItems > 0 && len(r.Items) > maxItems {
+		return fmt.Errorf("%d items is more than the limit of %d", len(r.Items), maxItems)
+	}
+	if maxStringLength <= 0 {
+		return nil
+	}
+	if len(r.Retailer) > maxStringLength {
+		return fmt.Errorf("retailer is longer than %d bytes", maxStringLength)
+	}
+	if len(r.Timezone) > maxStringLength {
+		return fmt.Errorf("timezone is longer than %d bytes", maxStringLength)
+	}
+	for i, item := range r.Items {
+		if len(item.ShortDescription) > maxStringLength {
+			return fmt.Errorf("item %d description is longer than %d bytes", i, maxStringLength)
+		}
+	}
+	return nil
 }
 
 func (r *Receipt) Validate() bool {
+	return r.InvalidField() == ""
+}
+
+// InvalidField names the first field of the receipt that fails validation,
+// or returns "" if it is valid. A purchase date, time and timezone that
+// are each well formed but do not make a valid time together are reported
+// as "purchasedAt".
+func (r *Receipt) InvalidField() string {
 	switch {
 	case r.Retailer == "":
-		return false
+		return "retailer"
 	case r.PurchaseDate == "":
-		return false
+		return "purchaseDate"
 	case r.PurchaseTime == "":
-		return false
+		return "purchaseTime"
 	case len(r.Items) == 0:
-		return false
-	case r.Total == "":
-		return false
+		return "items"
+	case r.Total == "", !amountPattern.MatchString(r.Total):
+		return "total"
 	}
-	return true
+
+	if _, err := r.PurchasedAt(); err != nil {
+		return "purchasedAt"
+	}
+
+	for _, item := range r.Items {
+		if !item.Validate() {
+			return "items"
+		}
+	}
+	return ""
+}
+
+var errLocalTimezone = errors.New("timezone Local is not allowed")
+
+// Location resolves the receipt's Timezone.
+func (r *Receipt) Location() (*time.Location, error) {
+	switch r.Timezone {
+	case "", "Z":
+		return time.UTC, nil
+	case "Local":
+		return nil, errLocalTimezone
+	}
+	if offset, err := time.Parse(offsetFmt, r.Timezone); err == nil {
+		_, seconds := offset.Zone()
+		return time.FixedZone("UTC"+r.Timezone, seconds), nil
+	}
+	return time.LoadLocation(r.Timezone)
+}
+
+// PurchasedAt returns the purchase instant in the store's local time.
+// A wall clock that falls in a daylight saving gap is moved forward by the
+// length of the gap, and one that is repeated when clocks fall back
+// resolves to the earlier of the two instants.
+func (r *Receipt) PurchasedAt() (time.Time, error) {
+	loc, err := r.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	wall, err := time.Parse(dateTimeFmt, r.PurchaseDate+" "+r.PurchaseTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid purchase date and time: %w", err)
+	}
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc)
+
+	// time.Date picks an arbitrary side of a gap, so compare the resolved
+	// wall clock with the requested one and shift forward by the difference.
+	resolved := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	if diff := wall.Sub(resolved); diff > 0 {
+		t = t.Add(diff)
+	}
+	return t, nil
 }
 
 type Item struct {
@@ -29,15 +135,237 @@ type Item struct {
 	Price            string `json:"price"`
 }
 
+func (i *Item) Validate() bool {
+	return amountPattern.MatchString(i.Price)
+}
+
 type ReceiptRecord struct {
 	Receipt
-	Points int
+	ID string
+	// Points is the total awarded, which is BasePoints scaled by the
+	// owner's tier multiplier.
+	Points     int
+	BasePoints int
+	Tier       string
+	// OwnerID is the ID of the user the points were credited to, if any.
+	OwnerID string
+	// ClientID is the authenticated client that submitted the receipt.
+	ClientID  string
+	CreatedAt time.Time
+	// Status is ReceiptStatusAccepted, or ReceiptStatusPending while the
+	// receipt is held for review. Reviewed receipts are
+	// ReceiptStatusApproved or ReceiptStatusRejected, and only accepted and
+	// approved receipts are credited.
+	Status      string
+	RiskScore   int
+	RiskReasons []string
+	ReviewedAt  *time.Time
+	ReviewedBy  string
+	ReviewNote  string
+	// VoidedAt is set when the receipt was reversed, for example after a
+	// return or when found fraudulent.
+	VoidedAt   *time.Time
+	VoidReason string
+}
+
+func (r *ReceiptRecord) Voided() bool {
+	return r.VoidedAt != nil
+}
+
+// Credited reports whether the receipt's points count towards its owner's
+// balance and tier.
+func (r *ReceiptRecord) Credited() bool {
+	switch r.Status {
+	case ReceiptStatusPending, ReceiptStatusRejected:
+		return false
+	}
+	return !r.Voided()
+}
+
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Subject is the token subject the user signs in as, if any.
+	Subject   string    `json:"subject,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+const (
+	LedgerEntryCredit   = "credit"
+	LedgerEntryDebit    = "debit"
+	LedgerEntryExpiry   = "expiry"
+	LedgerEntryReversal = "reversal"
+)
+
+// LedgerEntry is a change to a user's points balance. Credits have positive
+// Points, and debits and expiries negative Points. EarnedAt is when credited
+// points were earned, which for receipts is the purchase time. ReversesID
+// is the entry a compensating entry undoes, such as the debit of a
+// cancelled redemption.
+type LedgerEntry struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	Type        string    `json:"type"`
+	Points      int       `json:"points"`
+	ReceiptID   string    `json:"receiptId,omitempty"`
+	ReversesID  string    `json:"reversesId,omitempty"`
+	Description string    `json:"description,omitempty"`
+	EarnedAt    time.Time `json:"earnedAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type Reward struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Cost        int    `json:"cost"`
+	Inventory   int    `json:"inventory"`
+}
+
+func (r *Reward) Validate() bool {
+	switch {
+	case r.Name == "":
+		return false
+	case r.Cost <= 0:
+		return false
+	case r.Inventory < 0:
+		return false
+	}
+	return true
+}
+
+const (
+	RedemptionCompleted = "completed"
+	RedemptionCancelled = "cancelled"
+)
+
+// Redemption records points spent on a reward. DebitID and CreditID refer
+// to the ledger entries posted when it was made and cancelled.
+type Redemption struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"userId"`
+	RewardID    string     `json:"rewardId"`
+	Points      int        `json:"points"`
+	Status      string     `json:"status"`
+	DebitID     string     `json:"debitId"`
+	CreditID    string     `json:"creditId,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CancelledAt *time.Time `json:"cancelledAt,omitempty"`
 }
 
 type ProcessResponse struct {
-	ID string `json:"id"`
+	ID     string `json:"id"`
+	Status string `json:"status,omitempty"`
 }
 
+const (
+	ReceiptStatusAccepted = "accepted"
+	ReceiptStatusPending  = "pending"
+	ReceiptStatusApproved = "approved"
+	ReceiptStatusRejected = "rejected"
+	ReceiptStatusVoided   = "voided"
+)
+
 type PointsResponse struct {
-	Points int `json:"points"`
+	Points int    `json:"points"`
+	Status string `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type VoidRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// ReviewItem is a receipt as shown to reviewers.
+type ReviewItem struct {
+	ID          string     `json:"id"`
+	Receipt     Receipt    `json:"receipt"`
+	OwnerID     string     `json:"ownerId,omitempty"`
+	ClientID    string     `json:"clientId,omitempty"`
+	Points      int        `json:"points"`
+	Status      string     `json:"status"`
+	RiskScore   int        `json:"riskScore"`
+	RiskReasons []string   `json:"riskReasons"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ReviewedAt  *time.Time `json:"reviewedAt,omitempty"`
+	ReviewedBy  string     `json:"reviewedBy,omitempty"`
+	ReviewNote  string     `json:"reviewNote,omitempty"`
+}
+
+func NewReviewItem(r ReceiptRecord) ReviewItem {
+	reasons := r.RiskReasons
+	if reasons == nil {
+		reasons = []string{}
+	}
+	return ReviewItem{
+		ID:          r.ID,
+		Receipt:     r.Receipt,
+		OwnerID:     r.OwnerID,
+		ClientID:    r.ClientID,
+		Points:      r.Points,
+		Status:      r.Status,
+		RiskScore:   r.RiskScore,
+		RiskReasons: reasons,
+		CreatedAt:   r.CreatedAt,
+		ReviewedAt:  r.ReviewedAt,
+		ReviewedBy:  r.ReviewedBy,
+		ReviewNote:  r.ReviewNote,
+	}
+}
+
+type ReviewListResponse struct {
+	Receipts []ReviewItem `json:"receipts"`
+}
+
+type CreateUserRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateUserResponse struct {
+	ID string `json:"id"`
+}
+
+type BalanceResponse struct {
+	UserID  string `json:"userId"`
+	Balance int    `json:"balance"`
+}
+
+type LedgerResponse struct {
+	UserID  string        `json:"userId"`
+	Entries []LedgerEntry `json:"entries"`
+}
+
+// ExpiringBucket is the unspent remainder of a credit and when it expires.
+type ExpiringBucket struct {
+	EntryID   string    `json:"entryId"`
+	Points    int       `json:"points"`
+	EarnedAt  time.Time `json:"earnedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type ExpiringResponse struct {
+	UserID  string           `json:"userId"`
+	Points  int              `json:"points"`
+	Buckets []ExpiringBucket `json:"buckets"`
+}
+
+type TierResponse struct {
+	UserID            string `json:"userId"`
+	Tier              string `json:"tier"`
+	MultiplierPercent int    `json:"multiplierPercent"`
+	QualifyingPoints  int    `json:"qualifyingPoints"`
+	NextTier          string `json:"nextTier,omitempty"`
+	PointsToNextTier  int    `json:"pointsToNextTier,omitempty"`
+}
+
+type RewardsResponse struct {
+	Rewards []Reward `json:"rewards"`
+}
+
+type RedeemRequest struct {
+	RewardID string `json:"rewardId"`
 }
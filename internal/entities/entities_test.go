@@ -0,0 +1,183 @@
+package entities
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_PurchasedAt(t *testing.T) {
+	testCases := map[string]struct {
+		inputDate     string
+		inputTime     string
+		inputTimezone string
+		expectedUTC   string
+		expectedLocal string
+		expectError   bool
+	}{
+		"no timezone is utc": {
+			inputDate:     "2022-01-01",
+			inputTime:     "13:01",
+			expectedUTC:   "2022-01-01 13:01",
+			expectedLocal: "2022-01-01 13:01",
+		},
+		"iana timezone": {
+			inputDate:     "2022-01-01",
+			inputTime:     "13:01",
+			inputTimezone: "America/Chicago",
+			expectedUTC:   "2022-01-01 19:01",
+			expectedLocal: "2022-01-01 13:01",
+		},
+		"utc offset": {
+			inputDate:     "2022-01-01",
+			inputTime:     "01:30",
+			inputTimezone: "+05:30",
+			expectedUTC:   "2021-12-31 20:00",
+			expectedLocal: "2022-01-01 01:30",
+		},
+		"before spring forward": {
+			inputDate:     "2022-03-13",
+			inputTime:     "01:59",
+			inputTimezone: "America/New_York",
+			expectedUTC:   "2022-03-13 06:59",
+			expectedLocal: "2022-03-13 01:59",
+		},
+		"spring forward gap moves forward": {
+			inputDate:     "2022-03-13",
+			inputTime:     "02:30",
+			inputTimezone: "America/New_York",
+			expectedUTC:   "2022-03-13 07:30",
+			expectedLocal: "2022-03-13 03:30",
+		},
+		"after spring forward": {
+			inputDate:     "2022-03-13",
+			inputTime:     "03:00",
+			inputTimezone: "America/New_York",
+			expectedUTC:   "2022-03-13 07:00",
+			expectedLocal: "2022-03-13 03:00",
+		},
+		"fall back overlap resolves to earlier instant": {
+			inputDate:     "2022-11-06",
+			inputTime:     "01:30",
+			inputTimezone: "America/New_York",
+			expectedUTC:   "2022-11-06 05:30",
+			expectedLocal: "2022-11-06 01:30",
+		},
+		"after fall back": {
+			inputDate:     "2022-11-06",
+			inputTime:     "02:00",
+			inputTimezone: "America/New_York",
+			expectedUTC:   "2022-11-06 07:00",
+			expectedLocal: "2022-11-06 02:00",
+		},
+		"local timezone is rejected": {
+			inputDate:     "2022-01-01",
+			inputTime:     "13:01",
+			inputTimezone: "Local",
+			expectError:   true,
+		},
+		"unknown timezone": {
+			inputDate:     "2022-01-01",
+			inputTime:     "13:01",
+			inputTimezone: "Mars/Olympus_Mons",
+			expectError:   true,
+		},
+		"invalid time": {
+			inputDate:   "2022-01-01",
+			inputTime:   "25:00",
+			expectError: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			r := Receipt{PurchaseDate: tc.inputDate, PurchaseTime: tc.inputTime, Timezone: tc.inputTimezone}
+			purchasedAt, err := r.PurchasedAt()
+			if tc.expectError {
+				if err == nil {
+					t.Error("expected error but did not get one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if got := purchasedAt.UTC().Format(dateTimeFmt); got != tc.expectedUTC {
+				t.Errorf("unexpected utc time: got %s, want %s", got, tc.expectedUTC)
+			}
+			if got := purchasedAt.Format(dateTimeFmt); got != tc.expectedLocal {
+				t.Errorf("unexpected local time: got %s, want %s", got, tc.expectedLocal)
+			}
+		})
+	}
+}
+
+func Test_Location(t *testing.T) {
+	r := Receipt{Timezone: "-07:00"}
+	loc, err := r.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, offset := time.Date(2022, 1, 1, 0, 0, 0, 0, loc).Zone()
+	if offset != -7*60*60 {
+		t.Errorf("unexpected offset: got %d, want %d", offset, -7*60*60)
+	}
+}
+
+func Test_InvalidField(t *testing.T) {
+	valid := func() Receipt {
+		return Receipt{
+			Retailer:     "Target",
+			PurchaseDate: "2022-01-01",
+			PurchaseTime: "13:01",
+			Items:        []Item{{ShortDescription: "Mountain Dew 12PK", Price: "6.49"}},
+			Total:        "6.49",
+		}
+	}
+
+	testCases := map[string]struct {
+		modify   func(r *Receipt)
+		expected string
+	}{
+		"valid": {
+			modify: func(r *Receipt) {},
+		},
+		"retailer with punctuation": {
+			modify: func(r *Receipt) { r.Retailer = "24/7 Mart" },
+		},
+		"retailer with apostrophe": {
+			modify: func(r *Receipt) { r.Retailer = "Joe's" },
+		},
+		"retailer with accent": {
+			modify: func(r *Receipt) { r.Retailer = "Café" },
+		},
+		"description with punctuation": {
+			modify: func(r *Receipt) { r.Items[0].ShortDescription = "Klarbrunn 12-PK 12 FL OZ (x2)" },
+		},
+		"no retailer": {
+			modify:   func(r *Receipt) { r.Retailer = "" },
+			expected: "retailer",
+		},
+		"malformed total": {
+			modify:   func(r *Receipt) { r.Total = "6.4" },
+			expected: "total",
+		},
+		"malformed price": {
+			modify:   func(r *Receipt) { r.Items[0].Price = "six" },
+			expected: "items",
+		},
+		"invalid purchase time": {
+			modify:   func(r *Receipt) { r.PurchaseTime = "25:00" },
+			expected: "purchasedAt",
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			r := valid()
+			tc.modify(&r)
+			if got := r.InvalidField(); got != tc.expected {
+				t.Errorf("unexpected invalid field: got %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
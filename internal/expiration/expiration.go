@@ -0,0 +1,226 @@
+package expiration
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+// Policy sets when credited points expire. A zero field disables that kind
+// of expiry.
+type Policy struct {
+	// AfterMonths expires points this many months after they were earned.
+	AfterMonths int
+	// InactivityMonths expires every remaining point this many months after
+	// the user's last credit or debit. Expiries and reversals are not
+	// activity by the user.
+	InactivityMonths int
+}
+
+func (p Policy) Enabled() bool {
+	return p.AfterMonths > 0 || p.InactivityMonths > 0
+}
+
+// Buckets returns the unspent remainder of every credit in the ledger,
+// ordered by expiry. Debits and expiries consume the buckets that expire
+// first. A credit compensating a debit, such as a cancelled redemption,
+// gives points back to the buckets the debit consumed rather than starting
+// a new one. A reversal takes the points of its receipt's own credit, and
+// only the part of them already spent comes out of other buckets. Buckets
+// have a zero ExpiresAt when the policy is disabled.
+func (p Policy) Buckets(entries []entities.LedgerEntry) []entities.ExpiringBucket {
+	var (
+		buckets      []entities.ExpiringBucket
+		spent        int
+		lastActivity time.Time
+	)
+	reversed := make(map[string]int)
+	for _, entry := range entries {
+		if entry.Type == entities.LedgerEntryReversal && entry.ReceiptID != "" {
+			reversed[entry.ReceiptID] -= entry.Points
+		}
+	}
+
+	for _, entry := range entries {
+		switch {
+		case entry.Points > 0 && entry.ReversesID == "":
+			bucket := entities.ExpiringBucket{
+				EntryID:   entry.ID,
+				Points:    entry.Points,
+				EarnedAt:  entry.EarnedAt,
+				ExpiresAt: p.expiresAt(entry.EarnedAt),
+			}
+			if entry.ReceiptID != "" {
+				used := min(reversed[entry.ReceiptID], bucket.Points)
+				reversed[entry.ReceiptID] -= used
+				bucket.Points -= used
+			}
+			if bucket.Points > 0 {
+				buckets = append(buckets, bucket)
+			}
+		case entry.Type == entities.LedgerEntryReversal && entry.ReceiptID != "":
+			// Taken from the receipt's credit above.
+		default:
+			spent -= entry.Points
+		}
+		if entry.Type != entities.LedgerEntryExpiry && entry.Type != entities.LedgerEntryReversal && entry.CreatedAt.After(lastActivity) {
+			lastActivity = entry.CreatedAt
+		}
+	}
+
+	if p.InactivityMonths > 0 {
+		inactiveAt := lastActivity.AddDate(0, p.InactivityMonths, 0)
+		for i := range buckets {
+			if buckets[i].ExpiresAt.IsZero() || inactiveAt.Before(buckets[i].ExpiresAt) {
+				buckets[i].ExpiresAt = inactiveAt
+			}
+		}
+	}
+
+	sort.SliceStable(buckets, func(i, j int) bool {
+		if buckets[i].ExpiresAt.Equal(buckets[j].ExpiresAt) {
+			return buckets[i].EarnedAt.Before(buckets[j].EarnedAt)
+		}
+		return buckets[i].ExpiresAt.Before(buckets[j].ExpiresAt)
+	})
+
+	// Reversals of credits that are not in the ledger, or of more points than
+	// were credited, are spent like debits.
+	for _, points := range reversed {
+		spent += points
+	}
+
+	remaining := buckets[:0]
+	for _, bucket := range buckets {
+		used := min(spent, bucket.Points)
+		spent -= used
+		bucket.Points -= used
+		if bucket.Points > 0 {
+			remaining = append(remaining, bucket)
+		}
+	}
+	return remaining
+}
+
+// Reversible returns how many of the points credited for the receipt may
+// be taken back when it is voided: all of them but those that expired.
+// Spent points are reversible, since the user had them to spend.
+func (p Policy) Reversible(entries []entities.LedgerEntry, receiptID string) int {
+	var (
+		creditID string
+		kept     []entities.LedgerEntry
+	)
+	for _, entry := range entries {
+		switch {
+		case entry.Type == entities.LedgerEntryCredit && entry.ReceiptID == receiptID && entry.ReversesID == "":
+			creditID = entry.ID
+		case entry.Type == entities.LedgerEntryDebit || entry.ReversesID != "":
+			// Spending does not change which points expired.
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	if creditID == "" {
+		return 0
+	}
+
+	var points int
+	for _, bucket := range p.Buckets(kept) {
+		if bucket.EntryID == creditID {
+			points += bucket.Points
+		}
+	}
+	return points
+}
+
+// Expiring returns the buckets that expire before the deadline and their
+// total points.
+func (p Policy) Expiring(entries []entities.LedgerEntry, deadline time.Time) (int, []entities.ExpiringBucket) {
+	var (
+		points   int
+		expiring []entities.ExpiringBucket
+	)
+	if !p.Enabled() {
+		return 0, nil
+	}
+	for _, bucket := range p.Buckets(entries) {
+		if !bucket.ExpiresAt.After(deadline) {
+			points += bucket.Points
+			expiring = append(expiring, bucket)
+		}
+	}
+	return points, expiring
+}
+
+func (p Policy) expiresAt(earnedAt time.Time) time.Time {
+	if p.AfterMonths <= 0 {
+		return time.Time{}
+	}
+	return earnedAt.AddDate(0, p.AfterMonths, 0)
+}
+
+// Sweeper periodically posts expiries for every user's expired points.
+type Sweeper struct {
+	repository repositories.UsersRepository
+	policy     Policy
+	logger     *slog.Logger
+	now        func() time.Time
+}
+
+func NewSweeper(repository repositories.UsersRepository, policy Policy) *Sweeper {
+	return &Sweeper{
+		repository: repository,
+		policy:     policy,
+		logger:     slog.Default(),
+		now:        time.Now,
+	}
+}
+
+// Run sweeps every interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Sweep(); err != nil {
+				s.logger.Error("error sweeping expired points", "error", err.Error())
+			}
+		}
+	}
+}
+
+// Sweep posts an expiry for each user with expired points and returns the
+// total points expired.
+func (s *Sweeper) Sweep() (int, error) {
+	if !s.policy.Enabled() {
+		return 0, nil
+	}
+	userIDs, err := s.repository.ListUserIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int
+	now := s.now()
+	for _, userID := range userIDs {
+		entry, err := s.repository.ExpirePoints(userID, func(entries []entities.LedgerEntry) int {
+			points, _ := s.policy.Expiring(entries, now)
+			return points
+		})
+		if err != nil {
+			return total, err
+		}
+		if entry != nil {
+			s.logger.Info("expired points", "points", -entry.Points, "user_id", userID.String())
+			total -= entry.Points
+		}
+	}
+	return total, nil
+}
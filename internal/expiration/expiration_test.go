@@ -0,0 +1,260 @@
+package expiration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func credit(points int, earned, created string) entities.LedgerEntry {
+	return entities.LedgerEntry{Type: entities.LedgerEntryCredit, Points: points, EarnedAt: date(earned), CreatedAt: date(created)}
+}
+
+func receiptCredit(receiptID string, points int, earned string) entities.LedgerEntry {
+	entry := credit(points, earned, earned)
+	entry.ReceiptID = receiptID
+	return entry
+}
+
+func debit(points int, created string) entities.LedgerEntry {
+	return entities.LedgerEntry{Type: entities.LedgerEntryDebit, Points: -points, EarnedAt: date(created), CreatedAt: date(created)}
+}
+
+func Test_Expiring(t *testing.T) {
+	testCases := map[string]struct {
+		policy         Policy
+		entries        []entities.LedgerEntry
+		deadline       string
+		expectedPoints int
+		expectedCount  int
+	}{
+		"disabled policy": {
+			entries:  []entities.LedgerEntry{credit(100, "2022-01-01", "2022-01-01")},
+			deadline: "2030-01-01",
+		},
+		"expires after months": {
+			policy: Policy{AfterMonths: 12},
+			entries: []entities.LedgerEntry{
+				credit(100, "2022-01-01", "2022-01-01"),
+				credit(50, "2022-06-01", "2022-06-01"),
+			},
+			deadline:       "2023-01-01",
+			expectedPoints: 100,
+			expectedCount:  1,
+		},
+		"debits consume oldest points first": {
+			policy: Policy{AfterMonths: 12},
+			entries: []entities.LedgerEntry{
+				credit(100, "2022-01-01", "2022-01-01"),
+				credit(50, "2022-06-01", "2022-06-01"),
+				debit(120, "2022-07-01"),
+			},
+			deadline:       "2023-06-01",
+			expectedPoints: 30,
+			expectedCount:  1,
+		},
+		"ordered by purchase date not posting date": {
+			policy: Policy{AfterMonths: 12},
+			entries: []entities.LedgerEntry{
+				credit(100, "2022-06-01", "2022-06-01"),
+				credit(50, "2022-01-01", "2022-07-01"),
+				debit(60, "2022-08-01"),
+			},
+			deadline:       "2023-01-01",
+			expectedPoints: 0,
+		},
+		"earlier expiries are not expired twice": {
+			policy: Policy{AfterMonths: 12},
+			entries: []entities.LedgerEntry{
+				credit(100, "2022-01-01", "2022-01-01"),
+				credit(50, "2022-06-01", "2022-06-01"),
+				{Type: entities.LedgerEntryExpiry, Points: -100, CreatedAt: date("2023-01-02")},
+			},
+			deadline:       "2023-06-01",
+			expectedPoints: 50,
+			expectedCount:  1,
+		},
+		"cancelled debit restores the points it spent": {
+			policy: Policy{AfterMonths: 12},
+			entries: []entities.LedgerEntry{
+				credit(100, "2022-01-01", "2022-01-01"),
+				{ID: "redeemed", Type: entities.LedgerEntryDebit, Points: -100, CreatedAt: date("2022-12-01")},
+				{Type: entities.LedgerEntryCredit, Points: 100, ReversesID: "redeemed", EarnedAt: date("2022-12-15"), CreatedAt: date("2022-12-15")},
+			},
+			deadline:       "2023-01-01",
+			expectedPoints: 100,
+			expectedCount:  1,
+		},
+		"reversal takes its own receipt's points": {
+			policy: Policy{AfterMonths: 12},
+			entries: []entities.LedgerEntry{
+				receiptCredit("a", 100, "2022-01-01"),
+				receiptCredit("b", 50, "2022-06-01"),
+				{Type: entities.LedgerEntryReversal, Points: -50, ReceiptID: "b", CreatedAt: date("2022-07-01")},
+			},
+			deadline:       "2023-01-01",
+			expectedPoints: 100,
+			expectedCount:  1,
+		},
+		"reversal of spent points comes out of other credits": {
+			policy: Policy{AfterMonths: 12},
+			entries: []entities.LedgerEntry{
+				receiptCredit("a", 100, "2022-01-01"),
+				receiptCredit("b", 50, "2022-06-01"),
+				debit(120, "2022-07-01"),
+				{Type: entities.LedgerEntryReversal, Points: -50, ReceiptID: "b", CreatedAt: date("2022-08-01")},
+			},
+			deadline: "2023-06-01",
+		},
+		"inactivity expires everything": {
+			policy: Policy{AfterMonths: 24, InactivityMonths: 6},
+			entries: []entities.LedgerEntry{
+				credit(100, "2022-01-01", "2022-01-01"),
+				credit(50, "2022-03-01", "2022-03-01"),
+			},
+			deadline:       "2022-09-01",
+			expectedPoints: 150,
+			expectedCount:  2,
+		},
+		"activity postpones inactivity": {
+			policy: Policy{InactivityMonths: 6},
+			entries: []entities.LedgerEntry{
+				credit(100, "2022-01-01", "2022-01-01"),
+				debit(10, "2022-08-01"),
+			},
+			deadline: "2022-09-01",
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			points, buckets := tc.policy.Expiring(tc.entries, date(tc.deadline))
+			if points != tc.expectedPoints {
+				t.Errorf("unexpected expiring points: got %d, want %d", points, tc.expectedPoints)
+			}
+			if len(buckets) != tc.expectedCount {
+				t.Errorf("unexpected bucket count: got %d, want %d", len(buckets), tc.expectedCount)
+			}
+		})
+	}
+}
+
+func Test_Sweep(t *testing.T) {
+	m := repositories.New()
+	userID, err := m.CreateUser(entities.User{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, purchaseDate := range []string{"2022-01-01", "2022-06-01"} {
+		record := entities.ReceiptRecord{
+			Receipt: entities.Receipt{PurchaseDate: purchaseDate, PurchaseTime: "12:00"},
+			Points:  100,
+			OwnerID: userID,
+		}
+		if _, err := m.StoreReceipt(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewSweeper(m, Policy{AfterMonths: 12})
+	s.now = func() time.Time { return date("2023-03-01") }
+
+	for i, expected := range []int{100, 0} {
+		expired, err := s.Sweep()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expired != expected {
+			t.Errorf("sweep %d: unexpected expired points: got %d, want %d", i, expired, expected)
+		}
+	}
+
+	balance, err := m.GetBalance(uuid.MustParse(userID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != 100 {
+		t.Errorf("unexpected balance: got %d, want %d", balance, 100)
+	}
+}
+
+func Test_Buckets_voidedReceipt(t *testing.T) {
+	policy := Policy{AfterMonths: 12}
+	entries := []entities.LedgerEntry{
+		receiptCredit("a", 100, "2022-01-01"),
+		receiptCredit("b", 50, "2022-06-01"),
+		{Type: entities.LedgerEntryReversal, Points: -50, ReceiptID: "b", CreatedAt: date("2022-07-01")},
+	}
+	entries[0].ID, entries[1].ID = "credit-a", "credit-b"
+
+	buckets := policy.Buckets(entries)
+	if len(buckets) != 1 {
+		t.Fatalf("unexpected bucket count: got %d, want %d", len(buckets), 1)
+	}
+	if buckets[0].EntryID != "credit-a" || buckets[0].Points != 100 || !buckets[0].ExpiresAt.Equal(date("2023-01-01")) {
+		t.Errorf("unexpected bucket: %+v", buckets[0])
+	}
+}
+
+func Test_Reversible(t *testing.T) {
+	expiry := func(points int, created string) entities.LedgerEntry {
+		return entities.LedgerEntry{Type: entities.LedgerEntryExpiry, Points: -points, CreatedAt: date(created)}
+	}
+	withIDs := func(entries ...entities.LedgerEntry) []entities.LedgerEntry {
+		for i := range entries {
+			if entries[i].ReceiptID != "" {
+				entries[i].ID = "credit-" + entries[i].ReceiptID
+			}
+		}
+		return entries
+	}
+
+	testCases := map[string]struct {
+		entries  []entities.LedgerEntry
+		expected int
+	}{
+		"unexpired": {
+			entries:  withIDs(receiptCredit("a", 100, "2022-01-01")),
+			expected: 100,
+		},
+		"spent": {
+			entries:  withIDs(receiptCredit("a", 100, "2022-01-01"), debit(60, "2022-02-01")),
+			expected: 100,
+		},
+		"expired": {
+			entries:  withIDs(receiptCredit("a", 100, "2022-01-01"), expiry(100, "2023-01-01")),
+			expected: 0,
+		},
+		"partly spent, rest expired": {
+			entries:  withIDs(receiptCredit("a", 100, "2022-01-01"), debit(60, "2022-02-01"), expiry(40, "2023-01-01")),
+			expected: 60,
+		},
+		"another receipt expired": {
+			entries:  withIDs(receiptCredit("b", 50, "2021-06-01"), receiptCredit("a", 100, "2022-01-01"), expiry(50, "2022-06-01")),
+			expected: 100,
+		},
+		"not credited": {
+			entries:  withIDs(receiptCredit("b", 50, "2022-01-01")),
+			expected: 0,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			if got := (Policy{AfterMonths: 12}).Reversible(tc.entries, "a"); got != tc.expected {
+				t.Errorf("unexpected reversible points: got %d, want %d", got, tc.expected)
+			}
+		})
+	}
+}
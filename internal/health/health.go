@@ -0,0 +1,147 @@
+// Package health reports whether the service is alive and ready for
+// traffic.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+const (
+	StatusOK           = "ok"
+	StatusUnavailable  = "unavailable"
+	StatusShuttingDown = "shutting down"
+
+	// defaultCheckTimeout bounds how long readiness waits for each check.
+	defaultCheckTimeout = 2 * time.Second
+)
+
+var errCheckTimeout = errors.New("check timed out")
+
+// Check returns an error if a dependency cannot serve requests.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Response is the body of the health endpoints.
+type Response struct {
+	Status string `json:"status"`
+	// Checks maps each readiness check to "ok" or its error.
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Checker runs the readiness checks. It is ready until Drain is called.
+type Checker struct {
+	mu       sync.RWMutex
+	checks   []namedCheck
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func New() *Checker {
+	return &Checker{timeout: defaultCheckTimeout}
+}
+
+// Add registers a readiness check under name.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Drain marks the service not ready so load balancers stop sending it
+// traffic before it shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Liveness reports that the process is up and serving HTTP.
+func (c *Checker) Liveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, http.StatusOK, Response{Status: StatusOK})
+	}
+}
+
+// Readiness reports 200 OK when every check passes, and 503 Service
+// Unavailable when one fails or the service is draining.
+func (c *Checker) Readiness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.draining.Load() {
+			writeResponse(w, http.StatusServiceUnavailable, Response{Status: StatusShuttingDown})
+			return
+		}
+
+		c.mu.RLock()
+		checks := c.checks
+		c.mu.RUnlock()
+
+		res := Response{Status: StatusOK, Checks: make(map[string]string, len(checks))}
+		status := http.StatusOK
+		for _, nc := range checks {
+			if err := run(r.Context(), nc.check, c.timeout); err != nil {
+				res.Checks[nc.name] = err.Error()
+				res.Status = StatusUnavailable
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			res.Checks[nc.name] = StatusOK
+		}
+		writeResponse(w, status, res)
+	}
+}
+
+// run runs check, giving up after timeout even if the check ignores its
+// context.
+func run(ctx context.Context, check Check, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errCheckTimeout
+	}
+}
+
+// RepositoryCheck pings repository if it supports it.
+func RepositoryCheck(repository repositories.Repository) Check {
+	return func(ctx context.Context) error {
+		if p, ok := repository.(repositories.Pinger); ok {
+			return p.Ping()
+		}
+		return nil
+	}
+}
+
+// RulesetCheck checks that a valid ruleset is loaded.
+func RulesetCheck(rs *process.Ruleset) Check {
+	return func(ctx context.Context) error {
+		if rs == nil {
+			return errors.New("no ruleset loaded")
+		}
+		return rs.Validate()
+	}
+}
+
+func writeResponse(w http.ResponseWriter, status int, res Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
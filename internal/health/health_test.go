@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+func Test_Readiness(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("store unreachable") }
+	hanging := func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	testCases := map[string]struct {
+		checks         map[string]Check
+		drain          bool
+		expectedStatus int
+		expectedBody   Response
+	}{
+		"no checks": {
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Status: StatusOK},
+		},
+		"all checks pass": {
+			checks: map[string]Check{
+				"repository": RepositoryCheck(repositories.New()),
+				"ruleset":    RulesetCheck(process.DefaultRuleset()),
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   Response{Status: StatusOK, Checks: map[string]string{"repository": StatusOK, "ruleset": StatusOK}},
+		},
+		"failing check": {
+			checks:         map[string]Check{"repository": failing, "ruleset": ok},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   Response{Status: StatusUnavailable, Checks: map[string]string{"repository": "store unreachable", "ruleset": StatusOK}},
+		},
+		"missing ruleset": {
+			checks:         map[string]Check{"ruleset": RulesetCheck(nil)},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   Response{Status: StatusUnavailable, Checks: map[string]string{"ruleset": "no ruleset loaded"}},
+		},
+		"check times out": {
+			checks:         map[string]Check{"repository": hanging},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   Response{Status: StatusUnavailable, Checks: map[string]string{"repository": errCheckTimeout.Error()}},
+		},
+		"draining": {
+			checks:         map[string]Check{"ruleset": ok},
+			drain:          true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   Response{Status: StatusShuttingDown},
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			c := New()
+			c.timeout = 50 * time.Millisecond
+			for name, check := range tc.checks {
+				c.Add(name, check)
+			}
+			if tc.drain {
+				c.Drain()
+			}
+
+			w := httptest.NewRecorder()
+			c.Readiness()(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tc.expectedStatus {
+				t.Errorf("unexpected status: got %d, expected %d", w.Code, tc.expectedStatus)
+			}
+
+			var res Response
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != tc.expectedBody.Status {
+				t.Errorf("unexpected status field: got %q, expected %q", res.Status, tc.expectedBody.Status)
+			}
+			if len(res.Checks) != len(tc.expectedBody.Checks) {
+				t.Errorf("unexpected checks: got %v, expected %v", res.Checks, tc.expectedBody.Checks)
+			}
+			for name, expected := range tc.expectedBody.Checks {
+				if res.Checks[name] != expected {
+					t.Errorf("check %q: got %q, expected %q", name, res.Checks[name], expected)
+				}
+			}
+		})
+	}
+}
+
+func Test_Liveness(t *testing.T) {
+	c := New()
+	c.Add("repository", func(ctx context.Context) error { return errors.New("down") })
+	c.Drain()
+
+	w := httptest.NewRecorder()
+	c.Liveness()(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status: got %d, expected %d", w.Code, http.StatusOK)
+	}
+}
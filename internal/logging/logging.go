@@ -0,0 +1,228 @@
+// Package logging writes structured JSON logs and ties each line to the
+// request it was written for.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HeaderRequestID carries the request's correlation ID. Callers may send
+// one, and it is echoed on every response.
+const HeaderRequestID = "X-Request-ID"
+
+// validRequestID limits the IDs accepted from callers to ones safe to log.
+var validRequestID = regexp.MustCompile(`^[\w.:-]{1,128}$`)
+
+// New returns a logger writing JSON lines at level or above to w.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// ParseLevel reads a level name such as "debug" or "warn".
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
+type contextKey struct{}
+
+// requestState is the logger for one request, which handlers can add
+// attributes to as they learn more about it.
+type requestState struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+// FromContext returns the logger for the request ctx belongs to, or
+// fallback outside of a request.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		return state.logger
+	}
+	return fallback
+}
+
+// With adds attributes, such as the ID of the receipt being handled, to
+// every later log line for the request, including the one written when it
+// completes.
+func With(ctx context.Context, args ...any) {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.logger = state.logger.With(args...)
+	}
+}
+
+// RequestID returns the correlation ID of the request ctx belongs to.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type requestIDKey struct{}
+
+// Middleware assigns each request an ID, taken from its X-Request-ID header
+// when valid, and logs its route, status and latency when it completes.
+// Handlers log through FromContext so their lines carry the same ID.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(HeaderRequestID)
+			if !validRequestID.MatchString(requestID) {
+				requestID = newRequestID()
+			}
+			w.Header().Set(HeaderRequestID, requestID)
+
+			state := &requestState{logger: logger.With(
+				"request_id", requestID,
+				"method", r.Method,
+				"route", Route(r),
+			)}
+			ctx := context.WithValue(r.Context(), contextKey{}, state)
+			ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+
+			rec := NewResponseRecorder(w)
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			FromContext(ctx, logger).Info("request completed",
+				"status", rec.Status,
+				"latency_ms", float64(time.Since(start).Microseconds())/1000,
+				"bytes", rec.Bytes,
+			)
+		})
+	}
+}
+
+// NoRoute names requests that match no route, such as those answered with
+// 404 Not Found or 405 Method Not Allowed.
+const NoRoute = "unmatched"
+
+type routeKey struct{}
+
+// Routes names the route each request matches in router, for middleware
+// that wraps the router rather than being added to it with Use. Wrapping
+// lets that middleware see requests matching no route too, and Routes must
+// wrap it in turn.
+func Routes(router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := NoRoute
+			var match mux.RouteMatch
+			if router.Match(r, &match) && match.MatchErr == nil && match.Route != nil {
+				if tmpl, err := match.Route.GetPathTemplate(); err == nil {
+					route = stripPatterns(tmpl)
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, route)))
+		})
+	}
+}
+
+// Route names the matched route by its path template, with variable
+// patterns removed, such as /receipts/{id}/points. Outside the router it
+// returns the route found by Routes, or the request path without it.
+func Route(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			return stripPatterns(tmpl)
+		}
+	}
+	if route, ok := r.Context().Value(routeKey{}).(string); ok {
+		return route
+	}
+	return r.URL.Path
+}
+
+// stripPatterns removes the pattern from each variable in a path template.
+// Patterns may hold braces of their own, as in {id:[0-9a-f]{8}}, so a
+// variable ends at the brace matching the one that opened it.
+func stripPatterns(tmpl string) string {
+	var (
+		b         strings.Builder
+		depth     int
+		inPattern bool
+	)
+	for _, c := range tmpl {
+		switch {
+		case c == '{':
+			depth++
+			if depth == 1 {
+				b.WriteRune(c)
+				continue
+			}
+		case c == '}':
+			depth--
+			if depth == 0 {
+				inPattern = false
+				b.WriteRune(c)
+				continue
+			}
+		case c == ':' && depth == 1:
+			inPattern = true
+		}
+		if !inPattern {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ResponseRecorder remembers the status and size of a response, for
+// middleware that reports on it.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int
+	// WroteHeader is set once the response has been started.
+	WroteHeader bool
+}
+
+// NewResponseRecorder records the response written to w.
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (s *ResponseRecorder) WriteHeader(code int) {
+	if !s.WroteHeader {
+		s.Status = code
+		s.WroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *ResponseRecorder) Write(b []byte) (int, error) {
+	s.WroteHeader = true
+	n, err := s.ResponseWriter.Write(b)
+	s.Bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,192 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func Test_Middleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/receipts/{id:[0-9a-f-]+}/points", func(w http.ResponseWriter, r *http.Request) {
+		With(r.Context(), "receipt_id", mux.Vars(r)["id"])
+		FromContext(r.Context(), nil).Warn("receipt not found")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Use(Middleware(logger))
+
+	testCases := map[string]struct {
+		requestID  string
+		expectEcho bool
+	}{
+		"generated id": {},
+		"caller id": {
+			requestID:  "req-1234",
+			expectEcho: true,
+		},
+		"invalid caller id is replaced": {
+			requestID: "bad id\nwith newline",
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/receipts/abc-123/points", nil)
+			if tc.requestID != "" {
+				req.Header.Set(HeaderRequestID, tc.requestID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(HeaderRequestID)
+			if requestID == "" {
+				t.Fatal("no request id in response")
+			}
+			if tc.expectEcho != (requestID == tc.requestID) {
+				t.Errorf("unexpected request id: got %q, caller sent %q", requestID, tc.requestID)
+			}
+
+			var lines []map[string]interface{}
+			scanner := bufio.NewScanner(&buf)
+			for scanner.Scan() {
+				var line map[string]interface{}
+				if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+					t.Fatalf("log line is not JSON: %s", scanner.Text())
+				}
+				lines = append(lines, line)
+			}
+			if len(lines) != 2 {
+				t.Fatalf("unexpected number of log lines: got %d, want %d", len(lines), 2)
+			}
+			for _, line := range lines {
+				if line["request_id"] != requestID {
+					t.Errorf("unexpected request_id: got %v, want %q", line["request_id"], requestID)
+				}
+				if line["route"] != "/receipts/{id}/points" {
+					t.Errorf("unexpected route: got %v", line["route"])
+				}
+				if line["receipt_id"] != "abc-123" {
+					t.Errorf("unexpected receipt_id: got %v", line["receipt_id"])
+				}
+			}
+			completed := lines[1]
+			if completed["status"] != float64(http.StatusNotFound) {
+				t.Errorf("unexpected status: got %v, want %d", completed["status"], http.StatusNotFound)
+			}
+			if _, ok := completed["latency_ms"]; !ok {
+				t.Error("no latency in completion line")
+			}
+		})
+	}
+}
+
+func Test_Routes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/receipts/{id:[0-9a-f]{8}}/points", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	handler := Routes(r)(Middleware(logger)(r))
+
+	testCases := map[string]struct {
+		method        string
+		path          string
+		expStatusCode int
+		expRoute      string
+	}{
+		"matched": {
+			method:        http.MethodGet,
+			path:          "/receipts/0123abcd/points",
+			expStatusCode: http.StatusOK,
+			expRoute:      "/receipts/{id}/points",
+		},
+		"not found": {
+			method:        http.MethodGet,
+			path:          "/receipts/nope/points",
+			expStatusCode: http.StatusNotFound,
+			expRoute:      NoRoute,
+		},
+		"method not allowed": {
+			method:        http.MethodDelete,
+			path:          "/receipts/0123abcd/points",
+			expStatusCode: http.StatusMethodNotAllowed,
+			expRoute:      NoRoute,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			buf.Reset()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if w.Code != tc.expStatusCode {
+				t.Errorf("unexpected status code: got %d, want %d", w.Code, tc.expStatusCode)
+			}
+			if w.Header().Get(HeaderRequestID) == "" {
+				t.Error("no request id in response")
+			}
+			var line map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("log line is not JSON: %s", buf.String())
+			}
+			if line["route"] != tc.expRoute {
+				t.Errorf("unexpected route: got %v, want %q", line["route"], tc.expRoute)
+			}
+			if line["status"] != float64(tc.expStatusCode) {
+				t.Errorf("unexpected status: got %v, want %d", line["status"], tc.expStatusCode)
+			}
+		})
+	}
+}
+
+func Test_stripPatterns(t *testing.T) {
+	testCases := map[string]struct {
+		tmpl string
+		exp  string
+	}{
+		"no variables": {
+			tmpl: "/receipts/process",
+			exp:  "/receipts/process",
+		},
+		"variable without pattern": {
+			tmpl: "/rewards/{id}",
+			exp:  "/rewards/{id}",
+		},
+		"pattern with repetition counts": {
+			tmpl: "/receipts/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{12}}/points",
+			exp:  "/receipts/{id}/points",
+		},
+		"several variables": {
+			tmpl: "/users/{user:[0-9]{2}}/rewards/{reward:[a-z]+}",
+			exp:  "/users/{user}/rewards/{reward}",
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			if got := stripPatterns(tc.tmpl); got != tc.exp {
+				t.Errorf("unexpected route: got %q, want %q", got, tc.exp)
+			}
+		})
+	}
+}
+
+func Test_ParseLevel(t *testing.T) {
+	if level, err := ParseLevel("warn"); err != nil || level != slog.LevelWarn {
+		t.Errorf("unexpected level: got %v, %v", level, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected error but did not get one")
+	}
+}
@@ -0,0 +1,217 @@
+// Package metrics exposes service metrics in the Prometheus text format.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "receipts"
+
+// Reasons a request body or receipt is rejected before it is scored.
+const (
+	ReasonMalformedJSON = "malformed_json"
+	ReasonBodyTooLarge  = "body_too_large"
+	ReasonReceiptTooBig = "receipt_too_large"
+	// ReasonInvalidPrefix is followed by the name of the invalid field.
+	ReasonInvalidPrefix = "invalid_"
+)
+
+// Metrics records what the service does. A nil *Metrics records nothing, so
+// callers need not check whether metrics are enabled.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests           *prometheus.CounterVec
+	requestDuration    *prometheus.HistogramVec
+	receipts           *prometheus.CounterVec
+	validationFailures *prometheus.CounterVec
+	points             prometheus.Histogram
+	ruleHits           *prometheus.CounterVec
+	rulePoints         *prometheus.CounterVec
+	repository         *prometheus.HistogramVec
+	repositoryErrors   *prometheus.CounterVec
+	sizes              *sizeCollector
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		receipts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "processed_total",
+			Help:      "Receipts processed, by the status they were stored with.",
+		}, []string{"status"}),
+		validationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "validation_failures_total",
+			Help:      "Requests rejected before processing, by reason.",
+		}, []string{"reason"}),
+		points: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "points_awarded",
+			Help:      "Points awarded per processed receipt.",
+			Buckets:   []float64{0, 10, 25, 50, 75, 100, 150, 250, 500, 1000},
+		}),
+		ruleHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rule_hits_total",
+			Help:      "Processed receipts each scoring rule awarded points to.",
+		}, []string{"rule"}),
+		rulePoints: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rule_points_total",
+			Help:      "Points awarded by each scoring rule.",
+		}, []string{"rule"}),
+		repository: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_operation_duration_seconds",
+			Help:      "Time taken by repository operations.",
+			Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+		}, []string{"operation"}),
+		repositoryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "repository_errors_total",
+			Help:      "Repository operations that failed, not counting records that were not found.",
+		}, []string{"operation"}),
+		sizes: &sizeCollector{sizers: make(map[string]repositories.Sizer)},
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.receipts,
+		m.validationFailures,
+		m.points,
+		m.ruleHits,
+		m.rulePoints,
+		m.repository,
+		m.repositoryErrors,
+		m.sizes,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts requests and times them by route and status.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := logging.NewResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		labels := prometheus.Labels{
+			"method": r.Method,
+			"route":  logging.Route(r),
+			"status": strconv.Itoa(rec.Status),
+		}
+		m.requests.With(labels).Inc()
+		m.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ReceiptProcessed records a stored receipt, its status, and the points
+// each rule gave it before any tier multiplier.
+func (m *Metrics) ReceiptProcessed(status string, score process.Score) {
+	if m == nil {
+		return
+	}
+	m.receipts.WithLabelValues(status).Inc()
+	m.points.Observe(float64(score.Base))
+	for _, rule := range score.Rules {
+		m.ruleHits.WithLabelValues(rule.Rule).Inc()
+		m.rulePoints.WithLabelValues(rule.Rule).Add(float64(rule.Points))
+	}
+}
+
+// ValidationFailed records a request rejected for reason.
+func (m *Metrics) ValidationFailed(reason string) {
+	if m == nil {
+		return
+	}
+	m.validationFailures.WithLabelValues(reason).Inc()
+}
+
+// InstrumentRepository times every operation on repository, and reports
+// how many records it holds if it can count them. tenant labels the counts
+// when several repositories are instrumented.
+func (m *Metrics) InstrumentRepository(tenant string, repository repositories.Repository) repositories.Repository {
+	if sizer, ok := repository.(repositories.Sizer); ok {
+		m.sizes.add(tenant, sizer)
+	}
+	return repositories.WithObserver(repository, func(operation string) func(error) {
+		start := time.Now()
+		return func(err error) {
+			m.repository.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+			if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				m.repositoryErrors.WithLabelValues(operation).Inc()
+			}
+		}
+	})
+}
+
+var sizeDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "repository", "records"),
+	"Records held by the repository, by kind.",
+	[]string{"tenant", "kind"}, nil,
+)
+
+// sizeCollector reads the size of every instrumented repository when
+// metrics are scraped. A single collector serves every tenant, since the
+// registry only accepts one collector per metric.
+type sizeCollector struct {
+	mu     sync.Mutex
+	sizers map[string]repositories.Sizer
+}
+
+func (c *sizeCollector) add(tenant string, sizer repositories.Sizer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sizers[tenant] = sizer
+}
+
+func (c *sizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sizeDesc
+}
+
+func (c *sizeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for tenant, sizer := range c.sizers {
+		size := sizer.Size()
+		for kind, n := range map[string]int{
+			"receipts":    size.Receipts,
+			"users":       size.Users,
+			"rewards":     size.Rewards,
+			"redemptions": size.Redemptions,
+		} {
+			ch <- prometheus.MustNewConstMetric(sizeDesc, prometheus.GaugeValue, float64(n), tenant, kind)
+		}
+	}
+}
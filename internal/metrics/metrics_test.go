@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+	"github.com/gpayne44/fetch-challenge/internal/process"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	b, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func Test_Metrics(t *testing.T) {
+	m := New()
+
+	r := mux.NewRouter()
+	r.HandleFunc("/receipts/{id:[a-f]{3}-[0-9]{3}}/points", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := logging.Routes(r)(m.Middleware(r))
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/receipts/abc-123/points", nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	m.ReceiptProcessed(entities.ReceiptStatusAccepted, process.Score{Total: 24, Base: 16, Tier: "Gold", Rules: []process.RuleScore{
+		{Rule: process.RuleRetailerName, Points: 6},
+		{Rule: process.RuleTimeWindowPrefix + "happy hours", Points: 10},
+	}})
+	m.ValidationFailed(ReasonInvalidPrefix + "total")
+
+	repo := m.InstrumentRepository("acme", repositories.New())
+	m.InstrumentRepository("brunch-club", repositories.New())
+	if _, err := repo.StoreReceipt(entities.ReceiptRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetReceipt(uuid.New()); err != repositories.ErrNotFound {
+		t.Fatalf("unexpected error: got %v, want %v", err, repositories.ErrNotFound)
+	}
+
+	body := scrape(t, m)
+	for _, expected := range []string{
+		`receipts_http_requests_total{method="GET",route="/receipts/{id}/points",status="404"} 2`,
+		`receipts_http_request_duration_seconds_count{method="GET",route="/receipts/{id}/points",status="404"} 2`,
+		`receipts_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`receipts_processed_total{status="accepted"} 1`,
+		`receipts_points_awarded_sum 16`,
+		`receipts_rule_hits_total{rule="time_window:happy hours"} 1`,
+		`receipts_rule_points_total{rule="retailer_name"} 6`,
+		`receipts_validation_failures_total{reason="invalid_total"} 1`,
+		`receipts_repository_operation_duration_seconds_count{operation="GetReceipt"} 1`,
+		`receipts_repository_records{kind="receipts",tenant="acme"} 1`,
+		`receipts_repository_records{kind="receipts",tenant="brunch-club"} 0`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("metrics do not contain %q", expected)
+		}
+	}
+	if strings.Contains(body, "receipts_repository_errors_total{") {
+		t.Error("not found errors were counted as repository errors")
+	}
+}
+
+func Test_nilMetrics(t *testing.T) {
+	var m *Metrics
+	m.ReceiptProcessed(entities.ReceiptStatusAccepted, process.Score{})
+	m.ValidationFailed(ReasonMalformedJSON)
+}
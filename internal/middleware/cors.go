@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AllowAnyOrigin in CORS.AllowedOrigins lets pages on any origin call the
+// API.
+const AllowAnyOrigin = "*"
+
+// CORS decides which browser origins may call the API.
+type CORS struct {
+	// AllowedOrigins are the origins, such as "https://app.example.com",
+	// whose pages may call the API.
+	AllowedOrigins []string
+	// AllowedMethods and AllowedHeaders are what preflight requests may ask
+	// for.
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are response headers pages may read.
+	ExposedHeaders []string
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// DefaultCORS allows the given origins to use every route and header the
+// API defines.
+func DefaultCORS(origins []string) CORS {
+	return CORS{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{
+			"Authorization", "Content-Type", "Traceparent", "Tracestate",
+			"X-API-Key", "X-Request-ID", "X-Tenant-ID", "X-User-ID",
+		},
+		ExposedHeaders: []string{
+			"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After",
+		},
+		MaxAge: 10 * time.Minute,
+	}
+}
+
+// Handler adds CORS headers to responses for allowed origins and answers
+// their preflight requests. It wraps the router rather than being router
+// middleware, since preflight OPTIONS requests match no route.
+func (c CORS) Handler(next http.Handler) http.Handler {
+	methods := strings.Join(c.AllowedMethods, ", ")
+	headers := strings.Join(c.AllowedHeaders, ", ")
+	exposed := strings.Join(c.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(c.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !c.allows(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowOrigin := origin
+		if slices.Contains(c.AllowedOrigins, AllowAnyOrigin) {
+			allowOrigin = AllowAnyOrigin
+		}
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		if !preflight {
+			if exposed != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposed)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+		w.Header().Set("Access-Control-Max-Age", maxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (c CORS) allows(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == AllowAnyOrigin || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_CORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handled", "true")
+	})
+
+	testCases := map[string]struct {
+		origins        []string
+		method         string
+		origin         string
+		preflight      bool
+		expectedStatus int
+		expectedOrigin string
+		expectHandled  bool
+	}{
+		"same origin": {
+			origins:        []string{"https://app.example.com"},
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+			expectHandled:  true,
+		},
+		"allowed origin": {
+			origins:        []string{"https://app.example.com"},
+			method:         http.MethodGet,
+			origin:         "https://app.example.com",
+			expectedStatus: http.StatusOK,
+			expectedOrigin: "https://app.example.com",
+			expectHandled:  true,
+		},
+		"other origin": {
+			origins:        []string{"https://app.example.com"},
+			method:         http.MethodPost,
+			origin:         "https://evil.example.com",
+			expectedStatus: http.StatusOK,
+			expectHandled:  true,
+		},
+		"any origin": {
+			origins:        []string{AllowAnyOrigin},
+			method:         http.MethodGet,
+			origin:         "https://evil.example.com",
+			expectedStatus: http.StatusOK,
+			expectedOrigin: AllowAnyOrigin,
+			expectHandled:  true,
+		},
+		"preflight": {
+			origins:        []string{"https://app.example.com"},
+			method:         http.MethodOptions,
+			origin:         "https://app.example.com",
+			preflight:      true,
+			expectedStatus: http.StatusNoContent,
+			expectedOrigin: "https://app.example.com",
+		},
+		"preflight from other origin": {
+			origins:        []string{"https://app.example.com"},
+			method:         http.MethodOptions,
+			origin:         "https://evil.example.com",
+			preflight:      true,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/receipts/process", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				req.Header.Set("Access-Control-Request-Headers", "content-type, x-api-key")
+			}
+			w := httptest.NewRecorder()
+			DefaultCORS(tc.origins).Handler(next).ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("unexpected status: got %d, expected %d", w.Code, tc.expectedStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tc.expectedOrigin {
+				t.Errorf("unexpected allowed origin: got %q, expected %q", got, tc.expectedOrigin)
+			}
+			if handled := w.Header().Get("X-Handled") == "true"; handled != tc.expectHandled {
+				t.Errorf("unexpected handling: got %t, expected %t", handled, tc.expectHandled)
+			}
+			if tc.preflight && tc.expectedOrigin != "" {
+				if w.Header().Get("Access-Control-Allow-Methods") == "" || w.Header().Get("Access-Control-Allow-Headers") == "" {
+					t.Errorf("preflight response missing allowed methods or headers: %v", w.Header())
+				}
+			}
+		})
+	}
+}
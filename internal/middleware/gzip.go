@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minGzipBytes is the smallest response worth compressing. Smaller ones
+// are sent as they are, since gzip's framing would outweigh the savings.
+const minGzipBytes = 1024
+
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// Gzip compresses responses of at least minGzipBytes for clients that
+// accept gzip.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(gw, r)
+		// Not deferred: after a panic the buffered response is dropped so
+		// Recover can still send an error.
+		gw.Close()
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		if weight, err := strconv.ParseFloat(q, 64); err == nil && weight > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the response until it has minGzipBytes of
+// body, then compresses the rest.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	// gz is set once the response is being compressed, and passthrough once
+	// it is being sent uncompressed.
+	gz          *gzip.Writer
+	passthrough bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.status = code
+	g.wroteHeader = true
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	g.wroteHeader = true
+	switch {
+	case g.gz != nil:
+		return g.gz.Write(b)
+	case g.passthrough:
+		return g.ResponseWriter.Write(b)
+	}
+
+	g.buf.Write(b)
+	if g.buf.Len() < minGzipBytes {
+		return len(b), nil
+	}
+	// Already encoded responses are passed through untouched.
+	if g.Header().Get("Content-Encoding") != "" {
+		return len(b), g.sendPlain()
+	}
+
+	// Sniff the type from the uncompressed body, as net/http would.
+	if g.Header().Get("Content-Type") == "" {
+		g.Header().Set("Content-Type", http.DetectContentType(g.buf.Bytes()))
+	}
+	g.Header().Set("Content-Encoding", "gzip")
+	g.Header().Del("Content-Length")
+	g.ResponseWriter.WriteHeader(g.status)
+	g.gz = gzipWriters.Get().(*gzip.Writer)
+	g.gz.Reset(g.ResponseWriter)
+	_, err := g.gz.Write(g.buf.Bytes())
+	g.buf.Reset()
+	return len(b), err
+}
+
+// sendPlain sends the held back response uncompressed, along with any
+// later writes.
+func (g *gzipResponseWriter) sendPlain() error {
+	g.passthrough = true
+	g.ResponseWriter.WriteHeader(g.status)
+	_, err := g.ResponseWriter.Write(g.buf.Bytes())
+	g.buf.Reset()
+	return err
+}
+
+// Close finishes the response, sending it uncompressed if it never reached
+// minGzipBytes.
+func (g *gzipResponseWriter) Close() error {
+	switch {
+	case g.passthrough:
+		return nil
+	case g.gz == nil:
+		return g.sendPlain()
+	}
+	err := g.gz.Close()
+	gzipWriters.Put(g.gz)
+	g.gz = nil
+	g.passthrough = true
+	return err
+}
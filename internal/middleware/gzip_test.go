@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Gzip(t *testing.T) {
+	large := `{"receipts":"` + strings.Repeat("a", 2*minGzipBytes) + `"}`
+	small := `{"points":32}`
+
+	testCases := map[string]struct {
+		acceptEncoding string
+		body           string
+		encoding       string
+		expectGzip     bool
+	}{
+		"large response": {
+			acceptEncoding: "gzip, deflate, br",
+			body:           large,
+			expectGzip:     true,
+		},
+		"small response": {
+			acceptEncoding: "gzip",
+			body:           small,
+		},
+		"client without gzip": {
+			acceptEncoding: "br",
+			body:           large,
+		},
+		"gzip refused": {
+			acceptEncoding: "gzip;q=0, identity",
+			body:           large,
+		},
+		"gzip weighted": {
+			acceptEncoding: "br;q=1.0, gzip;q=0.8",
+			body:           large,
+			expectGzip:     true,
+		},
+		"already encoded": {
+			acceptEncoding: "gzip",
+			body:           large,
+			encoding:       "br",
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			handler := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tc.encoding != "" {
+					w.Header().Set("Content-Encoding", tc.encoding)
+				}
+				w.WriteHeader(http.StatusCreated)
+				// Write in pieces to cover responses that cross the
+				// threshold part way through.
+				for _, part := range []string{tc.body[:len(tc.body)/2], tc.body[len(tc.body)/2:]} {
+					io.WriteString(w, part)
+				}
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/rewards", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Errorf("unexpected status: got %d, expected %d", w.Code, http.StatusCreated)
+			}
+			if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("unexpected Vary %q", vary)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("unexpected content type %q", ct)
+			}
+
+			body := w.Body.Bytes()
+			if tc.expectGzip {
+				if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+					t.Fatalf("unexpected encoding %q", enc)
+				}
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			} else if enc := w.Header().Get("Content-Encoding"); enc != tc.encoding {
+				t.Errorf("unexpected encoding %q", enc)
+			}
+			if string(body) != tc.body {
+				t.Errorf("unexpected body of %d bytes, expected %d", len(body), len(tc.body))
+			}
+		})
+	}
+}
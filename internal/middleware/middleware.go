@@ -0,0 +1,66 @@
+// Package middleware holds the HTTP middleware that every route shares:
+// panic recovery, request deadlines, response compression and CORS.
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+)
+
+const errMsgInternal = "Internal server error."
+
+// ErrorResponse is the body written when a handler panics.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Recover turns a panic in a handler into a 500 Internal Server Error with
+// a JSON body, logging the panic and its stack. If the handler had already
+// started its response, the response is left as it is.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := logging.NewResponseRecorder(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			logging.FromContext(r.Context(), slog.Default()).Error("panic handling request",
+				"status", http.StatusInternalServerError, "panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+			if rec.WroteHeader {
+				return
+			}
+			w.Header().Del("Content-Encoding")
+			w.Header().Del("Content-Length")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: errMsgInternal})
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// Timeout gives each request a deadline of d, which handlers pass on to
+// scoring and storage through the request context. A d of 0 sets none.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
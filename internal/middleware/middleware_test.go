@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Recover(t *testing.T) {
+	testCases := map[string]struct {
+		handler        http.HandlerFunc
+		expectedStatus int
+		expectJSON     bool
+	}{
+		"no panic": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+			expectedStatus: http.StatusOK,
+		},
+		"panic before response": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				var m map[string]int
+				m["boom"]++
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectJSON:     true,
+		},
+		"panic after response started": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("too late")
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		"panic under gzip": {
+			handler: Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("partial"))
+				panic("boom")
+			})).ServeHTTP,
+			expectedStatus: http.StatusInternalServerError,
+			expectJSON:     true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/receipts", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			Recover(tc.handler).ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("unexpected status: got %d, expected %d", w.Code, tc.expectedStatus)
+			}
+			if !tc.expectJSON {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("unexpected content type %q", ct)
+			}
+			var res ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Error != errMsgInternal {
+				t.Errorf("unexpected body %q: %v", w.Body.String(), err)
+			}
+		})
+	}
+}
+
+func Test_Timeout(t *testing.T) {
+	var deadline time.Time
+	var hasDeadline bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, hasDeadline = r.Context().Deadline()
+		<-r.Context().Done()
+		if !errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			t.Errorf("unexpected context error: %v", r.Context().Err())
+		}
+	})
+
+	start := time.Now()
+	Timeout(20*time.Millisecond)(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !hasDeadline || deadline.Sub(start) > time.Second {
+		t.Errorf("unexpected deadline: %v, set %t", deadline, hasDeadline)
+	}
+
+	Timeout(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("deadline set with no timeout")
+		}
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
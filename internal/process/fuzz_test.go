@@ -0,0 +1,69 @@
+package process
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+// checkScoringInvariants fails the test if scoring the receipt breaks any
+// invariant that must hold for arbitrary input.
+func checkScoringInvariants(t *testing.T, receipt entities.Receipt) {
+	valid := receipt.Validate()
+	points, errs := CalculatePoints(receipt)
+	if points < 0 {
+		t.Errorf("negative point total %d for receipt %+v", points, receipt)
+	}
+	if valid && len(errs) != 0 {
+		t.Errorf("validated receipt produced scoring errors %v: %+v", errs, receipt)
+	}
+}
+
+func FuzzReceiptJSON(f *testing.F) {
+	paths, err := filepath.Glob(filepath.Join(goldenReceiptsDir, "*.json"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`{"items":[{}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var receipt entities.Receipt
+		if err := json.Unmarshal(data, &receipt); err != nil {
+			return
+		}
+		checkScoringInvariants(t, receipt)
+	})
+}
+
+func FuzzReceiptFields(f *testing.F) {
+	f.Add("Target", "2022-01-01", "13:01", "", "Mountain Dew 12PK", "6.49", "35.35")
+	f.Add("M&M Corner Market", "2022-03-20", "14:33", "America/New_York", "Gatorade", "2.25", "9.00")
+	f.Add("Walgreens", "2022-03-13", "02:30", "+05:30", "Dasani", "1.40", "1.40")
+	f.Add("", "", "", "", "", "", "")
+	f.Add("T & T", "2022-02-30", "25:00", "Local", "   ", "-1.00", "1e308")
+
+	f.Fuzz(func(t *testing.T, retailer, purchaseDate, purchaseTime, timezone, description, price, total string) {
+		receipt := entities.Receipt{
+			Retailer:     retailer,
+			PurchaseDate: purchaseDate,
+			PurchaseTime: purchaseTime,
+			Items: []entities.Item{
+				{ShortDescription: description, Price: price},
+			},
+			Total:    total,
+			Timezone: timezone,
+		}
+		checkScoringInvariants(t, receipt)
+	})
+}
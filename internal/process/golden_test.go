@@ -0,0 +1,72 @@
+package process
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+const goldenReceiptsDir = "../../testdata/receipts"
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata/receipts")
+
+// Test_CalculatePoints_golden scores every receipt in testdata/receipts and
+// compares the total with the matching .golden file. Run with -update to
+// regenerate the golden files after an intentional rule change.
+func Test_CalculatePoints_golden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(goldenReceiptsDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no receipts found in golden corpus")
+	}
+
+	for _, path := range paths {
+		caseName := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(caseName, func(t *testing.T) {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var receipt entities.Receipt
+			if err := json.Unmarshal(b, &receipt); err != nil {
+				t.Fatalf("could not unmarshal receipt: %s", err.Error())
+			}
+
+			points, errs := CalculatePoints(receipt)
+			if len(errs) != 0 {
+				t.Fatalf("unexpected errors calculating points: %v", errs)
+			}
+
+			goldenPath := strings.TrimSuffix(path, ".json") + ".golden"
+			if *update {
+				out, err := json.MarshalIndent(entities.PointsResponse{Points: points}, "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(goldenPath, append(out, '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			golden, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("could not read golden file, run with -update to create it: %s", err.Error())
+			}
+			var expected entities.PointsResponse
+			if err := json.Unmarshal(golden, &expected); err != nil {
+				t.Fatalf("could not unmarshal golden file: %s", err.Error())
+			}
+			if points != expected.Points {
+				t.Errorf("unexpected points: got %d, want %d", points, expected.Points)
+			}
+		})
+	}
+}
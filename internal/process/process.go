@@ -1,6 +1,8 @@
 package process
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"strconv"
 	"strings"
@@ -8,6 +10,11 @@ import (
 	"unicode/utf8"
 
 	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/spans"
+	"github.com/gpayne44/fetch-challenge/internal/tiers"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -17,44 +24,138 @@ const (
 	pointValueOddPurchaseDate = 6
 	pointValueHappyHours      = 10
 
-	dateFmt = "2006-01-02"
-	timeFmt = "15:04"
+	// maxAmount bounds prices and totals so point values fit in an int.
+	maxAmount = 1e12
+
+	instrumentationName = "github.com/gpayne44/fetch-challenge/internal/process"
 )
 
+// CalculatePoints scores the receipt with the default ruleset.
 func CalculatePoints(receipt entities.Receipt) (int, []error) {
-	var (
-		totalPoints int
-		errors      []error
-	)
-	totalPoints += calculateNamePoints(receipt.Retailer)
+	return DefaultRuleset().CalculatePoints(receipt)
+}
 
-	pricePoints, err := calculateTotalPricePoints(receipt.Total)
-	if err != nil {
-		errors = append(errors, err)
-	}
-	totalPoints += pricePoints
+// Names of the fixed rules in a Score. Time windows are reported under
+// their own names, prefixed with RuleTimeWindowPrefix.
+const (
+	RuleRetailerName     = "retailer_name"
+	RuleTotal            = "total"
+	RuleItemPairs        = "item_pairs"
+	RuleItemDescriptions = "item_descriptions"
+	RuleOddPurchaseDate  = "odd_purchase_date"
+	RuleTimeWindowPrefix = "time_window:"
+)
+
+// RuleScore is the points one rule awarded.
+type RuleScore struct {
+	Rule   string
+	Points int
+}
 
-	totalPoints += calculateItemsPoints(len(receipt.Items))
+// Score is a receipt's points broken down by the rules that awarded them.
+// Rules that awarded nothing are left out. Total is the points awarded,
+// which is Base scaled by the multiplier of Tier when the receipt was
+// scored for a member.
+type Score struct {
+	Total int
+	Base  int
+	Tier  string
+	Rules []RuleScore
+}
 
-	descriptionPoints, err := calculateDescriptionPoints(receipt.Items)
-	if err != nil {
-		errors = append(errors, err)
+func (s *Score) add(rule string, points int) {
+	if points == 0 {
+		return
 	}
-	totalPoints += descriptionPoints
+	s.Base += points
+	s.Rules = append(s.Rules, RuleScore{Rule: rule, Points: points})
+}
 
-	oddDatePoints, err := calculateOddDatePoints(receipt.PurchaseDate)
-	if err != nil {
-		errors = append(errors, err)
+func (rs *Ruleset) CalculatePoints(receipt entities.Receipt) (int, []error) {
+	score, errors := rs.Score(receipt)
+	return score.Total, errors
+}
+
+// Score scores the receipt and reports the points from each rule.
+func (rs *Ruleset) Score(receipt entities.Receipt) (Score, []error) {
+	return rs.ScoreContext(context.Background(), receipt)
+}
+
+// ScoreContext is Score, recording a span for the scoring and a child span
+// for each rule under the span in ctx. Rules are not run once ctx is done,
+// and its error is reported instead.
+func (rs *Ruleset) ScoreContext(ctx context.Context, receipt entities.Receipt) (Score, []error) {
+	return rs.ScoreForTier(ctx, receipt, nil)
+}
+
+// ScoreForTier is ScoreContext for a member of tier, whose multiplier
+// scales the total. A nil tier awards the base points.
+func (rs *Ruleset) ScoreForTier(ctx context.Context, receipt entities.Receipt, tier *tiers.Tier) (Score, []error) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "score receipt")
+	defer span.End()
+
+	var (
+		score   Score
+		errors  []error
+		stopped bool
+	)
+	rule := func(name string, calculate func() (int, error)) {
+		// Stop scoring once the caller has given up on the result.
+		if stopped {
+			return
+		}
+		if err := ctx.Err(); err != nil {
+			stopped = true
+			errors = append(errors, err)
+			return
+		}
+		_, span := otel.Tracer(instrumentationName).Start(ctx, "rule "+name, trace.WithAttributes(attribute.String("rule", name)))
+		defer span.End()
+		points, err := calculate()
+		if err != nil {
+			spans.Fail(span, err)
+			errors = append(errors, err)
+		}
+		span.SetAttributes(attribute.Int("points", points))
+		score.add(name, points)
 	}
-	totalPoints += oddDatePoints
 
-	happyHoursPoints, err := calculateHappyHoursPoints(receipt.PurchaseTime)
+	rule(RuleRetailerName, func() (int, error) {
+		return calculateNamePoints(receipt.Retailer), nil
+	})
+	rule(RuleTotal, func() (int, error) {
+		return calculateTotalPricePoints(receipt.Total)
+	})
+	rule(RuleItemPairs, func() (int, error) {
+		return calculateItemsPoints(len(receipt.Items)), nil
+	})
+	rule(RuleItemDescriptions, func() (int, error) {
+		return calculateDescriptionPoints(receipt.Items)
+	})
+
+	purchasedAt, err := receipt.PurchasedAt()
 	if err != nil {
+		spans.Fail(span, err)
 		errors = append(errors, err)
+	} else {
+		rule(RuleOddPurchaseDate, func() (int, error) {
+			return calculateOddDatePoints(purchasedAt), nil
+		})
+		for _, w := range rs.matchingWindows(purchasedAt) {
+			rule(RuleTimeWindowPrefix+w.Name, func() (int, error) {
+				return w.Points, nil
+			})
+		}
 	}
-	totalPoints += happyHoursPoints
 
-	return totalPoints, errors
+	score.Total = score.Base
+	if tier != nil {
+		score.Tier = tier.Name
+		score.Total = tier.Apply(score.Base)
+		span.SetAttributes(attribute.String("tier", tier.Name), attribute.Int("base_points", score.Base))
+	}
+	span.SetAttributes(attribute.Int("points", score.Total))
+	return score, errors
 }
 
 // one point for every alphanumeric character in the retailer name
@@ -77,7 +178,7 @@ func calculateNamePoints(retailerName string) int {
 // 50 points if the total is a round dollar amount with no cents
 // 25 points if the total is a multiple of 0.25
 func calculateTotalPricePoints(total string) (int, error) {
-	receiptTotal, err := strconv.ParseFloat(total, 64)
+	receiptTotal, err := parseAmount(total)
 	if err != nil {
 		return 0, err
 	}
@@ -111,7 +212,7 @@ func calculateDescriptionPoints(items []entities.Item) (int, error) {
 		trimmed := strings.TrimSpace(item.ShortDescription)
 		charCount := utf8.RuneCountInString(trimmed)
 		if charCount%3 == 0 {
-			priceFloat, err := strconv.ParseFloat(item.Price, 64)
+			priceFloat, err := parseAmount(item.Price)
 			if err != nil {
 				return 0, err
 			}
@@ -122,28 +223,36 @@ func calculateDescriptionPoints(items []entities.Item) (int, error) {
 	return descriptionPoints, nil
 }
 
-// 6 points if the day in the purchase date is odd.
-func calculateOddDatePoints(purchaseDate string) (int, error) {
-	parsedDate, err := time.Parse(dateFmt, purchaseDate)
+// parseAmount parses a price or total, rejecting values that are negative,
+// not finite or too large to score.
+func parseAmount(amount string) (float64, error) {
+	value, err := strconv.ParseFloat(amount, 64)
 	if err != nil {
 		return 0, err
 	}
-	dayVal := parsedDate.Day()
-	if dayVal%2 != 0 {
-		return pointValueOddPurchaseDate, nil
+	if math.IsNaN(value) || value < 0 || value >= maxAmount {
+		return 0, fmt.Errorf("amount %q out of range", amount)
 	}
-	return 0, nil
+	return value, nil
 }
 
-// 10 points if the time of purchase is after 2:00pm and before 4:00pm.
-func calculateHappyHoursPoints(purchaseTime string) (int, error) {
-	parsedTime, err := time.Parse(timeFmt, purchaseTime)
-	if err != nil {
-		return 0, err
+// 6 points if the day in the purchase date is odd, in the store's local time.
+func calculateOddDatePoints(purchasedAt time.Time) int {
+	dayVal := purchasedAt.Day()
+	if dayVal%2 != 0 {
+		return pointValueOddPurchaseDate
 	}
-	hour := parsedTime.Hour()
-	if hour == 14 || hour == 15 {
-		return pointValueHappyHours, nil
+	return 0
+}
+
+// matchingWindows returns the time windows the purchase falls in.
+func (rs *Ruleset) matchingWindows(purchasedAt time.Time) []TimeWindow {
+	var matched []TimeWindow
+	holiday := rs.isHoliday(purchasedAt)
+	for _, w := range rs.TimeWindows {
+		if w.matches(purchasedAt, holiday) {
+			matched = append(matched, w)
+		}
 	}
-	return 0, nil
+	return matched
 }
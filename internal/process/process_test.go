@@ -1,9 +1,14 @@
 package process
 
 import (
+	"context"
 	"testing"
 
 	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/tiers"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func Test_calculateNamePoints(t *testing.T) {
@@ -53,6 +58,14 @@ func Test_calculateTotalPricePoints(t *testing.T) {
 			inputPrice:  "asdf.00",
 			expectError: true,
 		},
+		"negative price": {
+			inputPrice:  "-10.00",
+			expectError: true,
+		},
+		"price is not finite": {
+			inputPrice:  "NaN",
+			expectError: true,
+		},
 	}
 
 	for caseName, tc := range testCases {
@@ -171,8 +184,8 @@ func Test_calculateDescriptionPoints(t *testing.T) {
 func Test_calculateOddDatePoints(t *testing.T) {
 	testCases := map[string]struct {
 		inputDate     string
+		inputTimezone string
 		expectedScore int
-		expectError   bool
 	}{
 		"valid odd date": {
 			inputDate:     "2022-01-01",
@@ -181,18 +194,26 @@ func Test_calculateOddDatePoints(t *testing.T) {
 		"valid even date": {
 			inputDate: "2022-03-20",
 		},
-		"invalid date": {
-			inputDate:   "202234-123-234-908",
-			expectError: true,
+		"even date in utc": {
+			inputDate: "2011-12-30",
+		},
+		// Samoa skipped December 30, 2011, so the wall clock falls in a day
+		// long gap and is moved forward to the 31st.
+		"skipped date moves to odd date in store timezone": {
+			inputDate:     "2011-12-30",
+			inputTimezone: "Pacific/Apia",
+			expectedScore: pointValueOddPurchaseDate,
 		},
 	}
 
 	for caseName, tc := range testCases {
 		t.Run(caseName, func(t *testing.T) {
-			score, err := calculateOddDatePoints(tc.inputDate)
-			if tc.expectError && err == nil {
-				t.Errorf("expected error but did not get one")
+			receipt := entities.Receipt{PurchaseDate: tc.inputDate, PurchaseTime: "23:30", Timezone: tc.inputTimezone}
+			purchasedAt, err := receipt.PurchasedAt()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
 			}
+			score := calculateOddDatePoints(purchasedAt)
 			if score != tc.expectedScore {
 				t.Errorf("unexpected score: got %d, want %d", score, tc.expectedScore)
 			}
@@ -200,11 +221,10 @@ func Test_calculateOddDatePoints(t *testing.T) {
 	}
 }
 
-func Test_calculateHappyHoursPoints(t *testing.T) {
+func Test_matchingWindows_default(t *testing.T) {
 	testCases := map[string]struct {
 		inputTime     string
 		expectedScore int
-		expectError   bool
 	}{
 		"scoring time": {
 			inputTime:     "14:33",
@@ -213,17 +233,28 @@ func Test_calculateHappyHoursPoints(t *testing.T) {
 		"non-scoring time": {
 			inputTime: "13:01",
 		},
-		"invalid time": {
-			inputTime:   "01:",
-			expectError: true,
+		"2:00pm is not after 2:00pm": {
+			inputTime: "14:00",
+		},
+		"last minute before 4:00pm": {
+			inputTime:     "15:59",
+			expectedScore: pointValueHappyHours,
+		},
+		"4:00pm is not before 4:00pm": {
+			inputTime: "16:00",
 		},
 	}
 
 	for caseName, tc := range testCases {
 		t.Run(caseName, func(t *testing.T) {
-			score, err := calculateHappyHoursPoints(tc.inputTime)
-			if tc.expectError && err == nil {
-				t.Errorf("expected error but did not get one")
+			receipt := entities.Receipt{PurchaseDate: "2022-01-01", PurchaseTime: tc.inputTime}
+			purchasedAt, err := receipt.PurchasedAt()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			var score int
+			for _, w := range DefaultRuleset().matchingWindows(purchasedAt) {
+				score += w.Points
 			}
 			if score != tc.expectedScore {
 				t.Errorf("unexpected score: got %d, want %d", score, tc.expectedScore)
@@ -231,3 +262,180 @@ func Test_calculateHappyHoursPoints(t *testing.T) {
 		})
 	}
 }
+
+func Test_CalculatePoints_invalidPurchaseTime(t *testing.T) {
+	testCases := map[string]entities.Receipt{
+		"invalid date": {
+			PurchaseDate: "202234-123-234-908",
+			PurchaseTime: "13:01",
+		},
+		"invalid time": {
+			PurchaseDate: "2022-01-01",
+			PurchaseTime: "01:",
+		},
+		"unknown timezone": {
+			PurchaseDate: "2022-01-01",
+			PurchaseTime: "13:01",
+			Timezone:     "Mars/Olympus_Mons",
+		},
+	}
+
+	for caseName, receipt := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			receipt.Total = "1.00"
+			_, errs := CalculatePoints(receipt)
+			if len(errs) == 0 {
+				t.Error("expected error but did not get one")
+			}
+		})
+	}
+}
+
+func Test_Score(t *testing.T) {
+	receipt := entities.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "15:01",
+		Items: []entities.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
+			{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+			{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: "12.00"},
+		},
+		Total: "35.35",
+	}
+
+	score, errs := DefaultRuleset().Score(receipt)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	expected := []RuleScore{
+		{Rule: RuleRetailerName, Points: 6},
+		{Rule: RuleItemPairs, Points: 10},
+		{Rule: RuleItemDescriptions, Points: 6},
+		{Rule: RuleOddPurchaseDate, Points: 6},
+		{Rule: RuleTimeWindowPrefix + "happy hours", Points: pointValueHappyHours},
+	}
+	if len(score.Rules) != len(expected) {
+		t.Fatalf("unexpected rules: got %+v, want %+v", score.Rules, expected)
+	}
+	for i := range expected {
+		if score.Rules[i] != expected[i] {
+			t.Errorf("unexpected rule %d: got %+v, want %+v", i, score.Rules[i], expected[i])
+		}
+	}
+	if total, _ := DefaultRuleset().CalculatePoints(receipt); score.Total != total || total != 38 {
+		t.Errorf("unexpected total: got %d, want %d", score.Total, 38)
+	}
+}
+
+func Test_ScoreForTier(t *testing.T) {
+	receipt := entities.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:01",
+		Items:        []entities.Item{{ShortDescription: "Mountain Dew 12PK", Price: "6.49"}},
+		Total:        "6.49",
+	}
+
+	testCases := map[string]struct {
+		tier          *tiers.Tier
+		expectedTotal int
+		expectedTier  string
+	}{
+		"no tier": {
+			expectedTotal: 6,
+		},
+		"bronze": {
+			tier:          &tiers.Default()[0],
+			expectedTotal: 6,
+			expectedTier:  "Bronze",
+		},
+		"gold rounds down": {
+			tier:          &tiers.Default()[2],
+			expectedTotal: 9,
+			expectedTier:  "Gold",
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			score, errs := DefaultRuleset().ScoreForTier(context.Background(), receipt, tc.tier)
+			if len(errs) != 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if score.Base != 6 {
+				t.Errorf("unexpected base points: got %d, want %d", score.Base, 6)
+			}
+			if score.Total != tc.expectedTotal {
+				t.Errorf("unexpected total: got %d, want %d", score.Total, tc.expectedTotal)
+			}
+			if score.Tier != tc.expectedTier {
+				t.Errorf("unexpected tier: got %q, want %q", score.Tier, tc.expectedTier)
+			}
+		})
+	}
+}
+
+func Test_ScoreContext_spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	receipt := entities.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "15:01",
+		Items:        []entities.Item{{ShortDescription: "Mountain Dew 12PK", Price: "6.49"}},
+		Total:        "6.49",
+	}
+	if _, errs := DefaultRuleset().ScoreContext(context.Background(), receipt); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	expected := []string{
+		"rule " + RuleRetailerName,
+		"rule " + RuleTotal,
+		"rule " + RuleItemPairs,
+		"rule " + RuleItemDescriptions,
+		"rule " + RuleOddPurchaseDate,
+		"rule " + RuleTimeWindowPrefix + "happy hours",
+		"score receipt",
+	}
+	spans := recorder.Ended()
+	if len(spans) != len(expected) {
+		t.Fatalf("unexpected span count: got %d, want %d", len(spans), len(expected))
+	}
+	parent := spans[len(spans)-1].SpanContext().SpanID()
+	for i, name := range expected {
+		if spans[i].Name() != name {
+			t.Errorf("span %d: got %q, want %q", i, spans[i].Name(), name)
+		}
+		if i < len(expected)-1 && spans[i].Parent().SpanID() != parent {
+			t.Errorf("%s is not a child of the score span", name)
+		}
+	}
+}
+
+func Test_ScoreContext_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	receipt := entities.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "15:01",
+		Items:        []entities.Item{{ShortDescription: "Mountain Dew 12PK", Price: "6.49"}},
+		Total:        "6.49",
+	}
+	score, errs := DefaultRuleset().ScoreContext(ctx, receipt)
+	if len(errs) != 1 || errs[0] != context.Canceled {
+		t.Fatalf("unexpected errors: got %v, want [%v]", errs, context.Canceled)
+	}
+	if score.Total != 0 || len(score.Rules) != 0 {
+		t.Errorf("rules ran after cancellation: %+v", score)
+	}
+}
@@ -0,0 +1,203 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	dateFmt = "2006-01-02"
+	timeFmt = "15:04"
+
+	dayWeekday = "weekday"
+	dayWeekend = "weekend"
+
+	HolidaysInclude = "include"
+	HolidaysOnly    = "only"
+	HolidaysExclude = "exclude"
+)
+
+// Ruleset holds the configurable scoring rules. The remaining rules from the
+// challenge specification are fixed and always applied.
+type Ruleset struct {
+	TimeWindows []TimeWindow `json:"timeWindows"`
+	// Holidays lists dates in the form 2006-01-02 that windows can match
+	// or exclude with their Holidays condition.
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// TimeWindow awards Points when the purchase time, in the store's local
+// time, falls between Start and End. A window whose End is before its Start
+// wraps past midnight.
+type TimeWindow struct {
+	Name           string    `json:"name"`
+	Start          ClockTime `json:"start"`
+	End            ClockTime `json:"end"`
+	StartInclusive bool      `json:"startInclusive,omitempty"`
+	EndInclusive   bool      `json:"endInclusive,omitempty"`
+	// Days restricts the window to days of the week, by name ("monday") or
+	// as "weekday" or "weekend". An empty list matches every day.
+	Days []string `json:"days,omitempty"`
+	// Holidays is one of "include" (the default), "only" or "exclude".
+	Holidays string `json:"holidays,omitempty"`
+	Points   int    `json:"points"`
+}
+
+// ClockTime is a time of day in minutes after midnight. It is encoded in
+// JSON as "15:04".
+type ClockTime int
+
+func (c ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+func (c ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *ClockTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	t, err := time.Parse(timeFmt, s)
+	if err != nil {
+		return err
+	}
+	*c = ClockTime(t.Hour()*60 + t.Minute())
+	return nil
+}
+
+// DefaultRuleset returns the rules from the challenge specification: 10
+// points if the time of purchase is after 2:00pm and before 4:00pm.
+func DefaultRuleset() *Ruleset {
+	return &Ruleset{
+		TimeWindows: []TimeWindow{
+			{
+				Name:   "happy hours",
+				Start:  14 * 60,
+				End:    16 * 60,
+				Points: pointValueHappyHours,
+			},
+		},
+	}
+}
+
+// LoadRuleset reads and validates a JSON ruleset file.
+func LoadRuleset(path string) (*Ruleset, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rs Ruleset
+	if err := json.Unmarshal(b, &rs); err != nil {
+		return nil, fmt.Errorf("could not unmarshal ruleset: %w", err)
+	}
+	if err := rs.Validate(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+func (rs *Ruleset) Validate() error {
+	for _, date := range rs.Holidays {
+		if _, err := time.Parse(dateFmt, date); err != nil {
+			return fmt.Errorf("invalid holiday %q: %w", date, err)
+		}
+	}
+	for i, w := range rs.TimeWindows {
+		if err := w.validate(); err != nil {
+			return fmt.Errorf("invalid time window %d %q: %w", i, w.Name, err)
+		}
+	}
+	return nil
+}
+
+func (rs *Ruleset) isHoliday(t time.Time) bool {
+	date := t.Format(dateFmt)
+	for _, holiday := range rs.Holidays {
+		if holiday == date {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *TimeWindow) validate() error {
+	if w.Start < 0 || w.Start >= 24*60 || w.End < 0 || w.End >= 24*60 {
+		return fmt.Errorf("times must be between 00:00 and 23:59")
+	}
+	if w.Start == w.End && !(w.StartInclusive && w.EndInclusive) {
+		return fmt.Errorf("start and end are equal")
+	}
+	if w.Points < 0 {
+		return fmt.Errorf("points must not be negative")
+	}
+	switch w.Holidays {
+	case "", HolidaysInclude, HolidaysOnly, HolidaysExclude:
+	default:
+		return fmt.Errorf("unknown holidays condition %q", w.Holidays)
+	}
+	for _, day := range w.Days {
+		if _, ok := parseDay(day); !ok && day != dayWeekday && day != dayWeekend {
+			return fmt.Errorf("unknown day %q", day)
+		}
+	}
+	return nil
+}
+
+// matches reports whether t, in the store's local time, falls in the window.
+func (w *TimeWindow) matches(t time.Time, holiday bool) bool {
+	switch {
+	case w.Holidays == HolidaysOnly && !holiday:
+		return false
+	case w.Holidays == HolidaysExclude && holiday:
+		return false
+	case !w.onDay(t.Weekday()):
+		return false
+	}
+
+	minutes := ClockTime(t.Hour()*60 + t.Minute())
+	afterStart := minutes > w.Start || (w.StartInclusive && minutes == w.Start)
+	beforeEnd := minutes < w.End || (w.EndInclusive && minutes == w.End)
+	if w.Start <= w.End {
+		return afterStart && beforeEnd
+	}
+	return afterStart || beforeEnd
+}
+
+func (w *TimeWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	weekend := day == time.Saturday || day == time.Sunday
+	for _, d := range w.Days {
+		switch d {
+		case dayWeekday:
+			if !weekend {
+				return true
+			}
+		case dayWeekend:
+			if weekend {
+				return true
+			}
+		default:
+			if parsed, ok := parseDay(d); ok && parsed == day {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseDay(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(name, day.String()) {
+			return day, true
+		}
+	}
+	return 0, false
+}
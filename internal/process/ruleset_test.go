@@ -0,0 +1,128 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_matchingWindows(t *testing.T) {
+	rs, err := LoadRuleset("testdata/rules/weekend-brunch.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		inputTime     string
+		expectedScore int
+	}{
+		"weekday happy hours": {
+			inputTime:     "2022-12-27 14:30",
+			expectedScore: 10,
+		},
+		"no happy hours on weekends": {
+			inputTime: "2022-12-24 14:30",
+		},
+		"no happy hours on holidays": {
+			inputTime:     "2022-12-26 14:30",
+			expectedScore: 20,
+		},
+		"inclusive start of brunch": {
+			inputTime:     "2022-12-24 10:00",
+			expectedScore: 15,
+		},
+		"exclusive end of brunch": {
+			inputTime: "2022-12-24 13:00",
+		},
+		"no brunch on weekdays": {
+			inputTime: "2022-12-27 11:00",
+		},
+		"late night before midnight": {
+			inputTime:     "2022-12-27 23:15",
+			expectedScore: 5,
+		},
+		"late night after midnight": {
+			inputTime:     "2022-12-28 01:15",
+			expectedScore: 5,
+		},
+		"inclusive end of late night": {
+			inputTime:     "2022-12-28 02:00",
+			expectedScore: 5,
+		},
+		"after late night": {
+			inputTime: "2022-12-28 02:01",
+		},
+		"overlapping windows add up": {
+			inputTime:     "2022-12-26 22:00",
+			expectedScore: 25,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			purchasedAt, err := time.Parse(dateFmt+" "+timeFmt, tc.inputTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var score int
+			for _, w := range rs.matchingWindows(purchasedAt) {
+				score += w.Points
+			}
+			if score != tc.expectedScore {
+				t.Errorf("unexpected score: got %d, want %d", score, tc.expectedScore)
+			}
+		})
+	}
+}
+
+func Test_Ruleset_Validate(t *testing.T) {
+	testCases := map[string]struct {
+		input       Ruleset
+		expectError bool
+	}{
+		"default ruleset": {
+			input: *DefaultRuleset(),
+		},
+		"empty ruleset": {
+			input: Ruleset{},
+		},
+		"invalid holiday": {
+			input:       Ruleset{Holidays: []string{"12/25/2022"}},
+			expectError: true,
+		},
+		"unknown day": {
+			input:       Ruleset{TimeWindows: []TimeWindow{{Start: 60, End: 120, Days: []string{"someday"}}}},
+			expectError: true,
+		},
+		"unknown holidays condition": {
+			input:       Ruleset{TimeWindows: []TimeWindow{{Start: 60, End: 120, Holidays: "sometimes"}}},
+			expectError: true,
+		},
+		"empty window": {
+			input:       Ruleset{TimeWindows: []TimeWindow{{Start: 60, End: 60}}},
+			expectError: true,
+		},
+		"single minute window": {
+			input: Ruleset{TimeWindows: []TimeWindow{{Start: 60, End: 60, StartInclusive: true, EndInclusive: true}}},
+		},
+		"time out of range": {
+			input:       Ruleset{TimeWindows: []TimeWindow{{Start: 60, End: 24 * 60}}},
+			expectError: true,
+		},
+		"negative points": {
+			input:       Ruleset{TimeWindows: []TimeWindow{{Start: 60, End: 120, Points: -1}}},
+			expectError: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			err := tc.input.Validate()
+			if tc.expectError && err == nil {
+				t.Error("expected error but did not get one")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+// Package ratelimit throttles requests per client with token buckets and
+// daily quotas.
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+)
+
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+
+	errMsgTooManyRequests = "Too many requests."
+	errMsgQuotaExceeded   = "The daily quota has been used up."
+)
+
+// maxTrackedClients bounds the number of buckets kept before idle ones are
+// pruned.
+const maxTrackedClients = 10000
+
+// Limit is the allowance for one route.
+type Limit struct {
+	// Rate is the number of requests per second a client may sustain.
+	Rate float64 `json:"rate"`
+	// Burst is the number of requests a client may make at once.
+	Burst int `json:"burst"`
+	// DailyQuota caps a client's requests per UTC day. 0 means no quota.
+	DailyQuota int `json:"dailyQuota,omitempty"`
+}
+
+// Config sets limits per route, keyed by method and path template, such as
+// "POST /receipts/process". Routes without an entry use Default, or are not
+// limited if Default is nil.
+type Config struct {
+	Default *Limit           `json:"default,omitempty"`
+	Routes  map[string]Limit `json:"routes,omitempty"`
+}
+
+// Load reads a JSON config from path.
+func Load(path string) (Config, error) {
+	var cfg Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("could not unmarshal rate limits: %w", err)
+	}
+	return cfg, cfg.Validate()
+}
+
+func (cfg Config) Validate() error {
+	if cfg.Default != nil {
+		if err := cfg.Default.validate(); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+	for route, limit := range cfg.Routes {
+		if err := limit.validate(); err != nil {
+			return fmt.Errorf("route %q: %w", route, err)
+		}
+	}
+	return nil
+}
+
+func (l Limit) validate() error {
+	switch {
+	case l.Rate <= 0 || math.IsInf(l.Rate, 0) || math.IsNaN(l.Rate):
+		return fmt.Errorf("rate must be positive")
+	case l.Burst < 1:
+		return fmt.Errorf("burst must be at least 1")
+	case l.DailyQuota < 0:
+		return fmt.Errorf("daily quota cannot be negative")
+	}
+	return nil
+}
+
+// Decision is the outcome of a request against its route's limit.
+type Decision struct {
+	Allowed bool
+	// QuotaExceeded is set when the request was refused by the daily quota
+	// rather than the rate.
+	QuotaExceeded bool
+	Limit         int
+	Remaining     int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long a refused client should wait.
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket will have refilled.
+	full time.Time
+	// used counts the requests allowed on day, for routes with a quota.
+	day   string
+	used  int
+	quota bool
+}
+
+// Limiter tracks a bucket per route and client.
+type Limiter struct {
+	mu      sync.Mutex
+	config  Config
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func New(config Config) *Limiter {
+	return &Limiter{
+		config:  config,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token for the client from the route's bucket.
+func (l *Limiter) Allow(route, client string) Decision {
+	limit, ok := l.config.Routes[route]
+	if !ok {
+		if l.config.Default == nil {
+			return Decision{Allowed: true}
+		}
+		limit = *l.config.Default
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	day := now.UTC().Format("2006-01-02")
+	key := route + "\x00" + client
+	b, ok := l.buckets[key]
+	if !ok {
+		l.prune(now)
+		b = &bucket{tokens: float64(limit.Burst), updated: now, day: day, quota: limit.DailyQuota > 0}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	if b.day != day {
+		b.day, b.used = day, 0
+	}
+
+	d := Decision{Limit: limit.Burst}
+	switch {
+	case limit.DailyQuota > 0 && b.used >= limit.DailyQuota:
+		d.QuotaExceeded = true
+		midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		d.RetryAfter = midnight.Sub(now)
+	case b.tokens < 1:
+		d.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	default:
+		d.Allowed = true
+		b.tokens--
+		b.used++
+	}
+	// Tokens left in the bucket cannot be spent until the quota resets.
+	if !d.QuotaExceeded {
+		d.Remaining = int(b.tokens)
+	}
+	d.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	b.full = now.Add(d.Reset)
+	return d
+}
+
+// prune drops buckets that have refilled and hold no quota usage for
+// today, so forgetting them changes nothing. It must be called with the
+// lock held.
+func (l *Limiter) prune(now time.Time) {
+	if len(l.buckets) < maxTrackedClients {
+		return
+	}
+	day := now.UTC().Format("2006-01-02")
+	for key, b := range l.buckets {
+		if now.Before(b.full) {
+			continue
+		}
+		if !b.quota || b.day != day {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
+
+// retryAfter rounds d up to whole seconds for the Retry-After header, so
+// clients never retry before they are allowed to, and never asks for
+// less than a second.
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+}
+
+// Middleware refuses requests over their route's limit with 429 Too Many
+// Requests. Limited routes report the client's allowance in RateLimit-*
+// headers. Clients are told apart by their authenticated identity, or by
+// address when there is none, so it should run after auth.Middleware.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := l.Allow(RouteKey(r), ClientKey(r))
+		if d.Limit == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set(HeaderLimit, strconv.Itoa(d.Limit))
+		w.Header().Set(HeaderRemaining, strconv.Itoa(d.Remaining))
+		w.Header().Set(HeaderReset, strconv.Itoa(int(d.Reset.Seconds())))
+		if !d.Allowed {
+			msg := errMsgTooManyRequests
+			if d.QuotaExceeded {
+				msg = errMsgQuotaExceeded
+			}
+			w.Header().Set(HeaderRetryAfter, retryAfter(d.RetryAfter))
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(msg))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RouteKey names the matched route by method and path template, with
+// variable patterns removed.
+func RouteKey(r *http.Request) string {
+	return r.Method + " " + logging.Route(r)
+}
+
+// ClientKey identifies who sent the request, and for which tenant: the
+// token subject, the API client, or failing both the remote address.
+// Callers not tied to a tenant get an allowance for each tenant they name
+// with auth.HeaderTenantID.
+func ClientKey(r *http.Request) string {
+	tenant := r.Header.Get(auth.HeaderTenantID)
+	if id, ok := auth.FromContext(r.Context()); ok {
+		if id.Tenant != "" {
+			tenant = id.Tenant
+		}
+		// Tokens name both the user and the app they signed in to, and
+		// each user gets their own allowance.
+		switch {
+		case id.Subject != "":
+			return "subject:" + tenant + "/" + id.Subject
+		case id.ClientID != "":
+			return "client:" + tenant + "/" + id.ClientID
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + tenant + "/" + host
+}
@@ -0,0 +1,196 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/auth"
+	"github.com/gpayne44/fetch-challenge/internal/controllers"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+)
+
+const routeProcess = "POST /receipts/process"
+
+func Test_Load(t *testing.T) {
+	cfg, err := Load("testdata/limits.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if cfg.Default == nil || cfg.Routes[routeProcess].DailyQuota != 1000 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	invalid := Config{Routes: map[string]Limit{routeProcess: {Rate: 1}}}
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for a zero burst but did not get one")
+	}
+}
+
+func Test_Allow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 23, 59, 50, 0, time.UTC)
+	l := New(Config{Routes: map[string]Limit{routeProcess: {Rate: 0.5, Burst: 2, DailyQuota: 3}}})
+	l.now = func() time.Time { return now }
+
+	steps := []struct {
+		advance       time.Duration
+		client        string
+		expAllowed    bool
+		expQuota      bool
+		expRemaining  int
+		expRetryAfter time.Duration
+	}{
+		{client: "a", expAllowed: true, expRemaining: 1},
+		{client: "a", expAllowed: true, expRemaining: 0},
+		{client: "a", expRemaining: 0, expRetryAfter: 2 * time.Second},
+		{client: "b", expAllowed: true, expRemaining: 1},
+		{advance: 2 * time.Second, client: "a", expAllowed: true, expRemaining: 0},
+		{advance: 2 * time.Second, client: "a", expQuota: true, expRemaining: 0, expRetryAfter: 6 * time.Second},
+		{advance: 6 * time.Second, client: "a", expAllowed: true, expRemaining: 1},
+	}
+
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		d := l.Allow(routeProcess, step.client)
+		if d.Allowed != step.expAllowed || d.QuotaExceeded != step.expQuota {
+			t.Errorf("step %d: unexpected decision: %+v", i, d)
+		}
+		if d.Remaining != step.expRemaining {
+			t.Errorf("step %d: unexpected remaining: got %d, want %d", i, d.Remaining, step.expRemaining)
+		}
+		if d.RetryAfter != step.expRetryAfter {
+			t.Errorf("step %d: unexpected retry after: got %s, want %s", i, d.RetryAfter, step.expRetryAfter)
+		}
+	}
+
+	if d := l.Allow("GET /rewards", "a"); !d.Allowed || d.Limit != 0 {
+		t.Errorf("unexpected decision for unlimited route: %+v", d)
+	}
+}
+
+func Test_Middleware(t *testing.T) {
+	l := New(Config{Routes: map[string]Limit{"GET /receipts/{id}/points": {Rate: 1, Burst: 1}}})
+	l.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	keys, err := auth.NewAPIKeys([]auth.APIKey{
+		{ClientID: "partner-a", Hash: auth.HashKey("partner-a-secret")},
+		{ClientID: "partner-b", Hash: auth.HashKey("partner-b-secret")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The service's own routes are used, since their variable patterns are
+	// what route keys must see past.
+	m := repositories.New()
+	first, err := m.StoreReceipt(entities.ReceiptRecord{Points: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.StoreReceipt(entities.ReceiptRecord{Points: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	controllers.New(m).Register(r)
+	r.Use(auth.Middleware(keys), l.Middleware)
+
+	testCases := []struct {
+		path          string
+		key           string
+		expStatusCode int
+		expRemaining  string
+		expRetryAfter string
+	}{
+		{path: "/receipts/" + first + "/points", key: "partner-a-secret", expStatusCode: http.StatusOK, expRemaining: "0"},
+		{path: "/receipts/" + second + "/points", key: "partner-a-secret", expStatusCode: http.StatusTooManyRequests, expRemaining: "0", expRetryAfter: "1"},
+		{path: "/receipts/" + first + "/points", key: "partner-b-secret", expStatusCode: http.StatusOK, expRemaining: "0"},
+		{path: "/rewards", key: "partner-a-secret", expStatusCode: http.StatusOK},
+	}
+
+	for i, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set(auth.HeaderAPIKey, tc.key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.expStatusCode {
+			t.Errorf("request %d: unexpected status code: got %d, want %d", i, w.Code, tc.expStatusCode)
+		}
+		if got := w.Header().Get(HeaderRemaining); got != tc.expRemaining {
+			t.Errorf("request %d: unexpected %s: got %q, want %q", i, HeaderRemaining, got, tc.expRemaining)
+		}
+		if got := w.Header().Get(HeaderRetryAfter); got != tc.expRetryAfter {
+			t.Errorf("request %d: unexpected %s: got %q, want %q", i, HeaderRetryAfter, got, tc.expRetryAfter)
+		}
+	}
+}
+
+func Test_Middleware_quotaExceeded(t *testing.T) {
+	l := New(Config{Routes: map[string]Limit{"GET /rewards": {Rate: 1, Burst: 5, DailyQuota: 1}}})
+	l.now = func() time.Time { return time.Date(2024, 5, 1, 23, 59, 59, 400*int(time.Millisecond), time.UTC) }
+
+	r := mux.NewRouter()
+	controllers.New(repositories.New()).Register(r)
+	r.Use(l.Middleware)
+
+	var w *httptest.ResponseRecorder
+	for range 2 {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rewards", nil))
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status code: got %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get(HeaderRemaining); got != "0" {
+		t.Errorf("unexpected %s: got %q, want %q", HeaderRemaining, got, "0")
+	}
+	// 0.6s are left until midnight, which must not be rounded down to 0.
+	if got := w.Header().Get(HeaderRetryAfter); got != "1" {
+		t.Errorf("unexpected %s: got %q, want %q", HeaderRetryAfter, got, "1")
+	}
+}
+
+func Test_ClientKey(t *testing.T) {
+	testCases := map[string]struct {
+		identity *auth.Identity
+		tenant   string
+		exp      string
+	}{
+		"subject": {
+			identity: &auth.Identity{Subject: "ada", ClientID: "app", Tenant: "acme"},
+			exp:      "subject:acme/ada",
+		},
+		"credentials tenant wins over header": {
+			identity: &auth.Identity{ClientID: "acme-app", Tenant: "acme"},
+			tenant:   "brunch-club",
+			exp:      "client:acme/acme-app",
+		},
+		"tenantless client counted per header tenant": {
+			identity: &auth.Identity{ClientID: "partner"},
+			tenant:   "brunch-club",
+			exp:      "client:brunch-club/partner",
+		},
+		"remote address": {
+			tenant: "acme",
+			exp:    "addr:acme/192.0.2.1",
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/rewards", nil)
+			if tc.tenant != "" {
+				r.Header.Set(auth.HeaderTenantID, tc.tenant)
+			}
+			if tc.identity != nil {
+				r = r.WithContext(auth.WithIdentity(r.Context(), *tc.identity))
+			}
+			if got := ClientKey(r); got != tc.exp {
+				t.Errorf("unexpected key: got %q, want %q", got, tc.exp)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+type bounded struct {
+	Repository
+	ctx context.Context
+}
+
+// WithContext fails every operation on repository with ctx's error once
+// ctx is done, so a request that has timed out or been cancelled stops
+// reading and writing records.
+func WithContext(ctx context.Context, repository Repository) Repository {
+	return &bounded{Repository: repository, ctx: ctx}
+}
+
+func (b *bounded) StoreReceipt(r entities.ReceiptRecord) (id string, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.StoreReceipt(r)
+}
+
+func (b *bounded) GetReceipt(id uuid.UUID) (record *entities.ReceiptRecord, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.GetReceipt(id)
+}
+
+func (b *bounded) ListReceipts(ownerID uuid.UUID) (records []entities.ReceiptRecord, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.ListReceipts(ownerID)
+}
+
+func (b *bounded) VoidReceipt(id uuid.UUID, reason string, reversible func([]entities.LedgerEntry) int) (record *entities.ReceiptRecord, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.VoidReceipt(id, reason, reversible)
+}
+
+func (b *bounded) ListReceiptsByStatus(status string) (records []entities.ReceiptRecord, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.ListReceiptsByStatus(status)
+}
+
+func (b *bounded) ReviewReceipt(id uuid.UUID, approve bool, reviewer, note string) (record *entities.ReceiptRecord, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.ReviewReceipt(id, approve, reviewer, note)
+}
+
+func (b *bounded) CreateUser(u entities.User) (id string, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.CreateUser(u)
+}
+
+func (b *bounded) GetUser(id uuid.UUID) (user *entities.User, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.GetUser(id)
+}
+
+func (b *bounded) UserForSubject(subject string) (user *entities.User, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.UserForSubject(subject)
+}
+
+func (b *bounded) GetBalance(userID uuid.UUID) (balance int, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.GetBalance(userID)
+}
+
+func (b *bounded) GetLedger(userID uuid.UUID) (entries []entities.LedgerEntry, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.GetLedger(userID)
+}
+
+func (b *bounded) ListUserIDs() (ids []uuid.UUID, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.ListUserIDs()
+}
+
+func (b *bounded) ExpirePoints(userID uuid.UUID, due func([]entities.LedgerEntry) int) (entry *entities.LedgerEntry, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.ExpirePoints(userID, due)
+}
+
+func (b *bounded) CreateReward(r entities.Reward) (id string, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.CreateReward(r)
+}
+
+func (b *bounded) GetReward(id uuid.UUID) (reward *entities.Reward, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.GetReward(id)
+}
+
+func (b *bounded) ListRewards() (rewards []entities.Reward, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.ListRewards()
+}
+
+func (b *bounded) Redeem(userID, rewardID uuid.UUID) (redemption *entities.Redemption, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.Redeem(userID, rewardID)
+}
+
+func (b *bounded) CancelRedemption(id uuid.UUID) (redemption *entities.Redemption, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.CancelRedemption(id)
+}
+
+func (b *bounded) GetRedemption(id uuid.UUID) (redemption *entities.Redemption, err error) {
+	if err = b.ctx.Err(); err != nil {
+		return
+	}
+	return b.Repository.GetRedemption(id)
+}
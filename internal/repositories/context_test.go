@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+func Test_WithContext(t *testing.T) {
+	store := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := WithContext(ctx, store)
+
+	id, err := repo.StoreReceipt(entities.ReceiptRecord{Points: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if _, err := repo.GetReceipt(uuid.MustParse(id)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: got %v, want %v", err, context.Canceled)
+	}
+	if _, err := repo.StoreReceipt(entities.ReceiptRecord{Points: 5}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: got %v, want %v", err, context.Canceled)
+	}
+	if size := store.Size(); size.Receipts != 1 {
+		t.Errorf("store written after cancellation: %d receipts", size.Receipts)
+	}
+}
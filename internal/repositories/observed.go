@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+// Observer is called as each repository operation starts, and the func it
+// returns as the operation ends with its error.
+type Observer func(operation string) func(err error)
+
+// Size counts the records in a repository.
+type Size struct {
+	Receipts    int
+	Users       int
+	Rewards     int
+	Redemptions int
+}
+
+// Sizer is implemented by repositories that can count their records.
+type Sizer interface {
+	Size() Size
+}
+
+// Pinger is implemented by repositories that can report whether their
+// backend is able to serve requests.
+type Pinger interface {
+	Ping() error
+}
+
+// Ping takes the store's lock, so it fails to return if an operation is
+// stuck holding it.
+func (m *memoryStore) Ping() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return nil
+}
+
+func (m *memoryStore) Size() Size {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return Size{
+		Receipts:    len(m.data),
+		Users:       len(m.users),
+		Rewards:     len(m.rewards),
+		Redemptions: len(m.redemptions),
+	}
+}
+
+type observed struct {
+	Repository
+	observe Observer
+}
+
+// WithObserver reports every operation on repository to observe.
+func WithObserver(repository Repository, observe Observer) Repository {
+	return &observed{Repository: repository, observe: observe}
+}
+
+// track starts observing operation. Deferring the func it returns with a
+// pointer to the named error result ends it.
+func (o *observed) track(operation string) func(*error) {
+	done := o.observe(operation)
+	return func(err *error) {
+		done(*err)
+	}
+}
+
+func (o *observed) StoreReceipt(r entities.ReceiptRecord) (id string, err error) {
+	defer o.track("StoreReceipt")(&err)
+	return o.Repository.StoreReceipt(r)
+}
+
+func (o *observed) GetReceipt(id uuid.UUID) (record *entities.ReceiptRecord, err error) {
+	defer o.track("GetReceipt")(&err)
+	return o.Repository.GetReceipt(id)
+}
+
+func (o *observed) ListReceipts(ownerID uuid.UUID) (records []entities.ReceiptRecord, err error) {
+	defer o.track("ListReceipts")(&err)
+	return o.Repository.ListReceipts(ownerID)
+}
+
+func (o *observed) VoidReceipt(id uuid.UUID, reason string, reversible func([]entities.LedgerEntry) int) (record *entities.ReceiptRecord, err error) {
+	defer o.track("VoidReceipt")(&err)
+	return o.Repository.VoidReceipt(id, reason, reversible)
+}
+
+func (o *observed) ListReceiptsByStatus(status string) (records []entities.ReceiptRecord, err error) {
+	defer o.track("ListReceiptsByStatus")(&err)
+	return o.Repository.ListReceiptsByStatus(status)
+}
+
+func (o *observed) ReviewReceipt(id uuid.UUID, approve bool, reviewer, note string) (record *entities.ReceiptRecord, err error) {
+	defer o.track("ReviewReceipt")(&err)
+	return o.Repository.ReviewReceipt(id, approve, reviewer, note)
+}
+
+func (o *observed) CreateUser(u entities.User) (id string, err error) {
+	defer o.track("CreateUser")(&err)
+	return o.Repository.CreateUser(u)
+}
+
+func (o *observed) GetUser(id uuid.UUID) (user *entities.User, err error) {
+	defer o.track("GetUser")(&err)
+	return o.Repository.GetUser(id)
+}
+
+func (o *observed) UserForSubject(subject string) (user *entities.User, err error) {
+	defer o.track("UserForSubject")(&err)
+	return o.Repository.UserForSubject(subject)
+}
+
+func (o *observed) GetBalance(userID uuid.UUID) (balance int, err error) {
+	defer o.track("GetBalance")(&err)
+	return o.Repository.GetBalance(userID)
+}
+
+func (o *observed) GetLedger(userID uuid.UUID) (entries []entities.LedgerEntry, err error) {
+	defer o.track("GetLedger")(&err)
+	return o.Repository.GetLedger(userID)
+}
+
+func (o *observed) ListUserIDs() (ids []uuid.UUID, err error) {
+	defer o.track("ListUserIDs")(&err)
+	return o.Repository.ListUserIDs()
+}
+
+func (o *observed) ExpirePoints(userID uuid.UUID, due func([]entities.LedgerEntry) int) (entry *entities.LedgerEntry, err error) {
+	defer o.track("ExpirePoints")(&err)
+	return o.Repository.ExpirePoints(userID, due)
+}
+
+func (o *observed) CreateReward(r entities.Reward) (id string, err error) {
+	defer o.track("CreateReward")(&err)
+	return o.Repository.CreateReward(r)
+}
+
+func (o *observed) GetReward(id uuid.UUID) (reward *entities.Reward, err error) {
+	defer o.track("GetReward")(&err)
+	return o.Repository.GetReward(id)
+}
+
+func (o *observed) ListRewards() (rewards []entities.Reward, err error) {
+	defer o.track("ListRewards")(&err)
+	return o.Repository.ListRewards()
+}
+
+func (o *observed) Redeem(userID, rewardID uuid.UUID) (redemption *entities.Redemption, err error) {
+	defer o.track("Redeem")(&err)
+	return o.Repository.Redeem(userID, rewardID)
+}
+
+func (o *observed) CancelRedemption(id uuid.UUID) (redemption *entities.Redemption, err error) {
+	defer o.track("CancelRedemption")(&err)
+	return o.Repository.CancelRedemption(id)
+}
+
+func (o *observed) GetRedemption(id uuid.UUID) (redemption *entities.Redemption, err error) {
+	defer o.track("GetRedemption")(&err)
+	return o.Repository.GetRedemption(id)
+}
+
+// Size counts the records in the observed repository, or reports zero if it
+// cannot.
+func (o *observed) Size() Size {
+	if s, ok := o.Repository.(Sizer); ok {
+		return s.Size()
+	}
+	return Size{}
+}
+
+func (o *observed) Ping() (err error) {
+	p, ok := o.Repository.(Pinger)
+	if !ok {
+		return nil
+	}
+	defer o.track("Ping")(&err)
+	return p.Ping()
+}
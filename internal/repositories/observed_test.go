@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+func Test_WithObserver(t *testing.T) {
+	type call struct {
+		operation string
+		err       error
+	}
+	var calls []call
+	repo := WithObserver(New(), func(operation string) func(error) {
+		return func(err error) {
+			calls = append(calls, call{operation: operation, err: err})
+		}
+	})
+
+	id, err := repo.StoreReceipt(entities.ReceiptRecord{Points: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetReceipt(uuid.MustParse(id)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetReceipt(uuid.New()); err != ErrNotFound {
+		t.Fatalf("unexpected error: got %v, want %v", err, ErrNotFound)
+	}
+
+	expected := []call{
+		{operation: "StoreReceipt"},
+		{operation: "GetReceipt"},
+		{operation: "GetReceipt", err: ErrNotFound},
+	}
+	if len(calls) != len(expected) {
+		t.Fatalf("unexpected calls: got %+v, want %+v", calls, expected)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("unexpected call %d: got %+v, want %+v", i, calls[i], expected[i])
+		}
+	}
+
+	if size := repo.(Sizer).Size(); size.Receipts != 1 {
+		t.Errorf("unexpected receipt count: got %d, want %d", size.Receipts, 1)
+	}
+}
@@ -2,44 +2,223 @@ package repositories
 
 import (
 	"errors"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gpayne44/fetch-challenge/internal/entities"
 )
 
 type memoryStore struct {
-	data map[uuid.UUID]entities.ReceiptRecord
+	mu    sync.RWMutex
+	data  map[uuid.UUID]entities.ReceiptRecord
+	users map[uuid.UUID]entities.User
+	// subjects indexes users by their token subject.
+	subjects map[string]uuid.UUID
+	ledger   map[uuid.UUID][]entities.LedgerEntry
+	// owned indexes receipt IDs by the user they were credited to.
+	owned map[uuid.UUID][]uuid.UUID
+	now   func() time.Time
+
+	rewards     map[uuid.UUID]entities.Reward
+	redemptions map[uuid.UUID]entities.Redemption
+}
+
+// Repository is the full set of storage operations used by the service.
+type Repository interface {
+	ReceiptsRepository
+	UsersRepository
+	RewardsRepository
 }
 
 type ReceiptsRepository interface {
+	// StoreReceipt stores the record and, when it has an owner and is not
+	// held, posts a credit for its points to the owner's ledger in the same
+	// transaction.
 	StoreReceipt(r entities.ReceiptRecord) (string, error)
 	GetReceipt(id uuid.UUID) (*entities.ReceiptRecord, error)
+	// ListReceipts returns the records owned by the user, oldest first.
+	ListReceipts(ownerID uuid.UUID) ([]entities.ReceiptRecord, error)
+	// VoidReceipt marks the record voided and, when its points were
+	// credited to an owner, posts a reversal in the same transaction. The
+	// reversal takes back no more than reversible reports from the owner's
+	// ledger, or every point if reversible is nil. reversible is called with
+	// the store locked so no other entry can be posted in between.
+	VoidReceipt(id uuid.UUID, reason string, reversible func([]entities.LedgerEntry) int) (*entities.ReceiptRecord, error)
+	// ListReceiptsByStatus returns the records with the status, oldest first.
+	ListReceiptsByStatus(status string) ([]entities.ReceiptRecord, error)
+	// ReviewReceipt approves or rejects a pending record. Approving credits
+	// the owner in the same transaction.
+	ReviewReceipt(id uuid.UUID, approve bool, reviewer, note string) (*entities.ReceiptRecord, error)
 }
 
-var ErrNotFound = errors.New("entity not found")
+var (
+	ErrNotFound      = errors.New("entity not found")
+	ErrAlreadyVoided = errors.New("receipt already voided")
+	ErrNotPending    = errors.New("receipt is not pending review")
+)
 
 func New() *memoryStore {
 	dataMap := make(map[uuid.UUID]entities.ReceiptRecord)
 	m := memoryStore{
-		data: dataMap,
+		data:     dataMap,
+		users:    make(map[uuid.UUID]entities.User),
+		subjects: make(map[string]uuid.UUID),
+		ledger:   make(map[uuid.UUID][]entities.LedgerEntry),
+		owned:    make(map[uuid.UUID][]uuid.UUID),
+		now:      time.Now,
+
+		rewards:     make(map[uuid.UUID]entities.Reward),
+		redemptions: make(map[uuid.UUID]entities.Redemption),
 	}
 	return &m
 }
 
-func (m memoryStore) StoreReceipt(r entities.ReceiptRecord) (string, error) {
+func (m *memoryStore) StoreReceipt(r entities.ReceiptRecord) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ownerID uuid.UUID
+	if r.OwnerID != "" {
+		var err error
+		ownerID, err = m.lookupUser(r.OwnerID)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	var id string
 
 	newID := uuid.New()
 	id = newID.String()
 
+	r.ID = id
+	r.CreatedAt = m.now().UTC()
 	m.data[newID] = r
+	if r.OwnerID != "" {
+		m.owned[ownerID] = append(m.owned[ownerID], newID)
+		if r.Credited() {
+			m.creditReceipt(ownerID, r)
+		}
+	}
 	return id, nil
 }
 
-func (m memoryStore) GetReceipt(id uuid.UUID) (*entities.ReceiptRecord, error) {
+// creditReceipt posts the record's points to the owner's ledger. The caller
+// must hold m.mu for writing.
+func (m *memoryStore) creditReceipt(ownerID uuid.UUID, r entities.ReceiptRecord) {
+	// Invalid purchase times are rejected before storing, so this only
+	// falls back to the posting time for hand built records.
+	earnedAt, _ := r.PurchasedAt()
+	m.appendEntry(ownerID, entities.LedgerEntry{
+		Type:        entities.LedgerEntryCredit,
+		Points:      r.Points,
+		ReceiptID:   r.ID,
+		Description: "points earned at " + r.Retailer,
+		EarnedAt:    earnedAt.UTC(),
+	})
+}
+
+func (m *memoryStore) GetReceipt(id uuid.UUID) (*entities.ReceiptRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	receipt, ok := m.data[id]
 	if !ok {
 		return nil, ErrNotFound
 	}
 	return &receipt, nil
 }
+
+func (m *memoryStore) ListReceipts(ownerID uuid.UUID) ([]entities.ReceiptRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := make([]entities.ReceiptRecord, 0, len(m.owned[ownerID]))
+	for _, id := range m.owned[ownerID] {
+		records = append(records, m.data[id])
+	}
+	return records, nil
+}
+
+func (m *memoryStore) VoidReceipt(id uuid.UUID, reason string, reversible func([]entities.LedgerEntry) int) (*entities.ReceiptRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.data[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if record.Voided() {
+		return nil, ErrAlreadyVoided
+	}
+	credited := record.Credited()
+
+	voidedAt := m.now().UTC()
+	record.VoidedAt = &voidedAt
+	record.VoidReason = reason
+	m.data[id] = record
+
+	if record.OwnerID != "" && credited && record.Points != 0 {
+		ownerID := uuid.MustParse(record.OwnerID)
+		points := record.Points
+		if reversible != nil {
+			points = min(points, reversible(m.ledger[ownerID]))
+		}
+		if points <= 0 {
+			return &record, nil
+		}
+		m.appendEntry(ownerID, entities.LedgerEntry{
+			Type:        entities.LedgerEntryReversal,
+			Points:      -points,
+			ReceiptID:   record.ID,
+			Description: "receipt voided: " + reason,
+		})
+	}
+	return &record, nil
+}
+
+func (m *memoryStore) ListReceiptsByStatus(status string) ([]entities.ReceiptRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var records []entities.ReceiptRecord
+	for _, record := range m.data {
+		if record.Status == status {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	return records, nil
+}
+
+func (m *memoryStore) ReviewReceipt(id uuid.UUID, approve bool, reviewer, note string) (*entities.ReceiptRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.data[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if record.Status != entities.ReceiptStatusPending || record.Voided() {
+		return nil, ErrNotPending
+	}
+
+	reviewedAt := m.now().UTC()
+	record.Status = entities.ReceiptStatusRejected
+	if approve {
+		record.Status = entities.ReceiptStatusApproved
+	}
+	record.ReviewedAt = &reviewedAt
+	record.ReviewedBy = reviewer
+	record.ReviewNote = note
+	m.data[id] = record
+
+	if approve && record.OwnerID != "" {
+		m.creditReceipt(uuid.MustParse(record.OwnerID), record)
+	}
+	return &record, nil
+}
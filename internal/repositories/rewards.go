@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+var (
+	ErrInsufficientPoints = errors.New("insufficient points")
+	ErrOutOfStock         = errors.New("reward out of stock")
+	ErrAlreadyCancelled   = errors.New("redemption already cancelled")
+)
+
+type RewardsRepository interface {
+	CreateReward(r entities.Reward) (string, error)
+	GetReward(id uuid.UUID) (*entities.Reward, error)
+	// ListRewards returns the catalog sorted by name.
+	ListRewards() ([]entities.Reward, error)
+	// Redeem takes one unit of the reward's inventory and debits its cost
+	// from the user's ledger. It fails without changes if the user cannot
+	// afford the reward or it is out of stock.
+	Redeem(userID, rewardID uuid.UUID) (*entities.Redemption, error)
+	// CancelRedemption returns the unit to inventory and posts a credit
+	// that compensates the original debit, giving back the points it spent
+	// with their original expiry.
+	CancelRedemption(id uuid.UUID) (*entities.Redemption, error)
+	GetRedemption(id uuid.UUID) (*entities.Redemption, error)
+}
+
+func (m *memoryStore) CreateReward(r entities.Reward) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	newID := uuid.New()
+	r.ID = newID.String()
+
+	m.rewards[newID] = r
+	return r.ID, nil
+}
+
+func (m *memoryStore) GetReward(id uuid.UUID) (*entities.Reward, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reward, ok := m.rewards[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &reward, nil
+}
+
+func (m *memoryStore) ListRewards() ([]entities.Reward, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rewards := make([]entities.Reward, 0, len(m.rewards))
+	for _, reward := range m.rewards {
+		rewards = append(rewards, reward)
+	}
+	sort.Slice(rewards, func(i, j int) bool {
+		if rewards[i].Name == rewards[j].Name {
+			return rewards[i].ID < rewards[j].ID
+		}
+		return rewards[i].Name < rewards[j].Name
+	})
+	return rewards, nil
+}
+
+func (m *memoryStore) Redeem(userID, rewardID uuid.UUID) (*entities.Redemption, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, ErrNotFound
+	}
+	reward, ok := m.rewards[rewardID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if reward.Inventory <= 0 {
+		return nil, ErrOutOfStock
+	}
+	if m.balance(userID) < reward.Cost {
+		return nil, ErrInsufficientPoints
+	}
+
+	reward.Inventory--
+	m.rewards[rewardID] = reward
+
+	newID := uuid.New()
+	debit := m.appendEntry(userID, entities.LedgerEntry{
+		Type:        entities.LedgerEntryDebit,
+		Points:      -reward.Cost,
+		Description: "redeemed " + reward.Name,
+	})
+	redemption := entities.Redemption{
+		ID:        newID.String(),
+		UserID:    userID.String(),
+		RewardID:  reward.ID,
+		Points:    reward.Cost,
+		Status:    entities.RedemptionCompleted,
+		DebitID:   debit.ID,
+		CreatedAt: debit.CreatedAt,
+	}
+	m.redemptions[newID] = redemption
+	return &redemption, nil
+}
+
+func (m *memoryStore) CancelRedemption(id uuid.UUID) (*entities.Redemption, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	redemption, ok := m.redemptions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if redemption.Status == entities.RedemptionCancelled {
+		return nil, ErrAlreadyCancelled
+	}
+
+	rewardID := uuid.MustParse(redemption.RewardID)
+	reward := m.rewards[rewardID]
+	reward.Inventory++
+	m.rewards[rewardID] = reward
+
+	credit := m.appendEntry(uuid.MustParse(redemption.UserID), entities.LedgerEntry{
+		Type:        entities.LedgerEntryCredit,
+		Points:      redemption.Points,
+		ReversesID:  redemption.DebitID,
+		Description: "cancelled redemption of " + reward.Name,
+	})
+	redemption.Status = entities.RedemptionCancelled
+	redemption.CreditID = credit.ID
+	redemption.CancelledAt = &credit.CreatedAt
+	m.redemptions[id] = redemption
+	return &redemption, nil
+}
+
+func (m *memoryStore) GetRedemption(id uuid.UUID) (*entities.Redemption, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	redemption, ok := m.redemptions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &redemption, nil
+}
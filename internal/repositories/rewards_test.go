@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+// newFundedUser creates a user and credits them with points.
+func newFundedUser(t *testing.T, m *memoryStore, points int) uuid.UUID {
+	t.Helper()
+	userID, err := m.CreateUser(entities.User{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.StoreReceipt(entities.ReceiptRecord{Points: points, OwnerID: userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uuid.MustParse(userID)
+}
+
+func Test_Redeem_concurrent(t *testing.T) {
+	testCases := map[string]struct {
+		balance        int
+		inventory      int
+		expectedRedeem int
+	}{
+		"limited by balance": {
+			balance:        100,
+			inventory:      50,
+			expectedRedeem: 10,
+		},
+		"limited by inventory": {
+			balance:        1000,
+			inventory:      5,
+			expectedRedeem: 5,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			m := New()
+			userID := newFundedUser(t, m, tc.balance)
+			rewardID, err := m.CreateReward(entities.Reward{Name: "Sticker", Cost: 10, Inventory: tc.inventory})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				wg        sync.WaitGroup
+				mu        sync.Mutex
+				succeeded int
+			)
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := m.Redeem(userID, uuid.MustParse(rewardID))
+					if err == nil {
+						mu.Lock()
+						succeeded++
+						mu.Unlock()
+					} else if err != ErrInsufficientPoints && err != ErrOutOfStock {
+						t.Errorf("unexpected error: %s", err.Error())
+					}
+				}()
+			}
+			wg.Wait()
+
+			if succeeded != tc.expectedRedeem {
+				t.Errorf("unexpected redemptions: got %d, want %d", succeeded, tc.expectedRedeem)
+			}
+			balance, err := m.GetBalance(userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance != tc.balance-10*tc.expectedRedeem {
+				t.Errorf("unexpected balance: got %d, want %d", balance, tc.balance-10*tc.expectedRedeem)
+			}
+			if balance < 0 {
+				t.Errorf("balance overdrawn: %d", balance)
+			}
+		})
+	}
+}
+
+func Test_CancelRedemption(t *testing.T) {
+	m := New()
+	userID := newFundedUser(t, m, 25)
+	rewardID, err := m.CreateReward(entities.Reward{Name: "Mug", Cost: 20, Inventory: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redemption, err := m.Redeem(userID, uuid.MustParse(rewardID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Redeem(userID, uuid.MustParse(rewardID)); err != ErrOutOfStock {
+		t.Errorf("unexpected error redeeming sold out reward: got %v, want %v", err, ErrOutOfStock)
+	}
+
+	cancelled, err := m.CancelRedemption(uuid.MustParse(redemption.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != entities.RedemptionCancelled || cancelled.CreditID == "" || cancelled.CancelledAt == nil {
+		t.Errorf("unexpected cancelled redemption: %+v", cancelled)
+	}
+	if _, err := m.CancelRedemption(uuid.MustParse(redemption.ID)); err != ErrAlreadyCancelled {
+		t.Errorf("unexpected error cancelling twice: got %v, want %v", err, ErrAlreadyCancelled)
+	}
+
+	balance, _ := m.GetBalance(userID)
+	if balance != 25 {
+		t.Errorf("unexpected balance: got %d, want %d", balance, 25)
+	}
+	ledger, _ := m.GetLedger(userID)
+	if len(ledger) != 3 {
+		t.Errorf("unexpected ledger entry count: got %d, want %d", len(ledger), 3)
+	}
+	reward, _ := m.GetReward(uuid.MustParse(rewardID))
+	if reward.Inventory != 1 {
+		t.Errorf("unexpected inventory: got %d, want %d", reward.Inventory, 1)
+	}
+}
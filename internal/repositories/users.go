@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+type UsersRepository interface {
+	CreateUser(u entities.User) (string, error)
+	GetUser(id uuid.UUID) (*entities.User, error)
+	// UserForSubject returns the user signed in as subject, creating one the
+	// first time the subject is seen.
+	UserForSubject(subject string) (*entities.User, error)
+	// GetBalance returns the sum of every entry in the user's ledger.
+	GetBalance(userID uuid.UUID) (int, error)
+	// GetLedger returns the user's ledger entries, oldest first.
+	GetLedger(userID uuid.UUID) ([]entities.LedgerEntry, error)
+	ListUserIDs() ([]uuid.UUID, error)
+	// ExpirePoints posts an expiry for the points due reports as expired in
+	// the user's ledger, returning nil if nothing is due. due is called with
+	// the store locked so no other entry can be posted in between.
+	ExpirePoints(userID uuid.UUID, due func([]entities.LedgerEntry) int) (*entities.LedgerEntry, error)
+}
+
+func (m *memoryStore) CreateUser(u entities.User) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	newID := uuid.New()
+	u.ID = newID.String()
+	u.CreatedAt = m.now().UTC()
+
+	m.users[newID] = u
+	return u.ID, nil
+}
+
+func (m *memoryStore) GetUser(id uuid.UUID) (*entities.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (m *memoryStore) UserForSubject(subject string) (*entities.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id, ok := m.subjects[subject]; ok {
+		user := m.users[id]
+		return &user, nil
+	}
+
+	newID := uuid.New()
+	user := entities.User{
+		ID:        newID.String(),
+		Name:      subject,
+		Subject:   subject,
+		CreatedAt: m.now().UTC(),
+	}
+	m.users[newID] = user
+	m.subjects[subject] = newID
+	return &user, nil
+}
+
+func (m *memoryStore) GetBalance(userID uuid.UUID) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return 0, ErrNotFound
+	}
+	return m.balance(userID), nil
+}
+
+func (m *memoryStore) GetLedger(userID uuid.UUID) ([]entities.LedgerEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, ErrNotFound
+	}
+	entries := make([]entities.LedgerEntry, len(m.ledger[userID]))
+	copy(entries, m.ledger[userID])
+	return entries, nil
+}
+
+func (m *memoryStore) ListUserIDs() ([]uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]uuid.UUID, 0, len(m.users))
+	for id := range m.users {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (m *memoryStore) ExpirePoints(userID uuid.UUID, due func([]entities.LedgerEntry) int) (*entities.LedgerEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, ErrNotFound
+	}
+	points := due(m.ledger[userID])
+	if points <= 0 {
+		return nil, nil
+	}
+	entry := m.appendEntry(userID, entities.LedgerEntry{
+		Type:        entities.LedgerEntryExpiry,
+		Points:      -points,
+		Description: "points expired",
+	})
+	return &entry, nil
+}
+
+// lookupUser parses id and checks the user exists. The caller must hold m.mu.
+func (m *memoryStore) lookupUser(id string) (uuid.UUID, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, ErrNotFound
+	}
+	if _, ok := m.users[userID]; !ok {
+		return uuid.Nil, ErrNotFound
+	}
+	return userID, nil
+}
+
+// balance sums the user's ledger. The caller must hold m.mu.
+func (m *memoryStore) balance(userID uuid.UUID) int {
+	var total int
+	for _, entry := range m.ledger[userID] {
+		total += entry.Points
+	}
+	return total
+}
+
+// appendEntry adds an entry to the user's ledger. Entries are never changed
+// or removed once appended. The caller must hold m.mu for writing.
+func (m *memoryStore) appendEntry(userID uuid.UUID, entry entities.LedgerEntry) entities.LedgerEntry {
+	entry.ID = uuid.New().String()
+	entry.UserID = userID.String()
+	entry.CreatedAt = m.now().UTC()
+	if entry.EarnedAt.IsZero() {
+		entry.EarnedAt = entry.CreatedAt
+	}
+	m.ledger[userID] = append(m.ledger[userID], entry)
+	return entry
+}
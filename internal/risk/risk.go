@@ -0,0 +1,252 @@
+package risk
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+const maxScore = 100
+
+// Input is a receipt submission to assess.
+type Input struct {
+	Receipt entities.Receipt
+	// Submitter identifies who sent the receipt, such as a user ID or the
+	// client address.
+	Submitter string
+	Now       time.Time
+}
+
+// Signal is a reason a receipt looks risky and how much it adds to the score.
+// Field names the receipt field when the signal is a soft validation
+// failure: a field that is well formed but implausible.
+type Signal struct {
+	Score  int
+	Reason string
+	Field  string
+}
+
+// Check inspects a submission and returns a signal when it finds a risk.
+type Check interface {
+	Check(in Input) (Signal, bool)
+}
+
+// CheckFunc adapts a function to a Check.
+type CheckFunc func(in Input) (Signal, bool)
+
+func (f CheckFunc) Check(in Input) (Signal, bool) {
+	return f(in)
+}
+
+// Assessment is the result of evaluating a submission. Hold is set when the
+// receipt should wait for review before its points are credited, which is
+// when the score reaches the hold threshold or any field is implausible.
+type Assessment struct {
+	Score   int
+	Reasons []string
+	// Fields are the implausible fields, if any.
+	Fields []string
+	Hold   bool
+}
+
+type Evaluator interface {
+	Evaluate(in Input) Assessment
+}
+
+type Config struct {
+	// HoldThreshold is the score at which receipts are held. Zero holds
+	// only receipts with implausible fields.
+	HoldThreshold int
+	// FutureTolerance allows for clock skew before a purchase time counts
+	// as future dated.
+	FutureTolerance time.Duration
+	// MaxTotal is the largest plausible receipt total.
+	MaxTotal float64
+	// BurstLimit is the number of receipts a submitter may send within
+	// BurstWindow before further receipts are flagged.
+	BurstLimit  int
+	BurstWindow time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		HoldThreshold:   70,
+		FutureTolerance: 24 * time.Hour,
+		MaxTotal:        5000,
+		BurstLimit:      20,
+		BurstWindow:     time.Minute,
+	}
+}
+
+type evaluator struct {
+	holdThreshold int
+	checks        []Check
+}
+
+// New returns an evaluator that sums the scores of the checks, capped at 100.
+func New(holdThreshold int, checks ...Check) Evaluator {
+	return &evaluator{holdThreshold: holdThreshold, checks: checks}
+}
+
+// NewDefault returns an evaluator running every built in check.
+func NewDefault(cfg Config) Evaluator {
+	return New(cfg.HoldThreshold,
+		FutureDated(cfg.FutureTolerance),
+		ImplausibleTotal(cfg.MaxTotal),
+		ItemsMismatchTotal(),
+		OddRetailerName(),
+		NewBurstCheck(cfg.BurstLimit, cfg.BurstWindow),
+	)
+}
+
+func (e *evaluator) Evaluate(in Input) Assessment {
+	var assessment Assessment
+	for _, check := range e.checks {
+		if signal, ok := check.Check(in); ok {
+			assessment.Score += signal.Score
+			assessment.Reasons = append(assessment.Reasons, signal.Reason)
+			if signal.Field != "" {
+				assessment.Fields = append(assessment.Fields, signal.Field)
+			}
+		}
+	}
+	assessment.Score = min(assessment.Score, maxScore)
+	assessment.Hold = len(assessment.Fields) > 0 || (e.holdThreshold > 0 && assessment.Score >= e.holdThreshold)
+	return assessment
+}
+
+// FutureDated flags receipts purchased after now plus the tolerance.
+func FutureDated(tolerance time.Duration) Check {
+	return CheckFunc(func(in Input) (Signal, bool) {
+		purchasedAt, err := in.Receipt.PurchasedAt()
+		if err != nil || !purchasedAt.After(in.Now.Add(tolerance)) {
+			return Signal{}, false
+		}
+		return Signal{Score: 70, Reason: "purchase date is in the future", Field: "purchaseDate"}, true
+	})
+}
+
+// ImplausibleTotal flags totals above maxTotal.
+func ImplausibleTotal(maxTotal float64) Check {
+	return CheckFunc(func(in Input) (Signal, bool) {
+		total, err := strconv.ParseFloat(in.Receipt.Total, 64)
+		if err != nil || total <= maxTotal {
+			return Signal{}, false
+		}
+		return Signal{Score: 40, Reason: "total is implausibly large", Field: "total"}, true
+	})
+}
+
+// ItemsMismatchTotal flags receipts whose item prices add up to far more or
+// less than the total. A quarter of the total plus a dollar is allowed for
+// tax, discounts and rounding.
+func ItemsMismatchTotal() Check {
+	return CheckFunc(func(in Input) (Signal, bool) {
+		total, err := strconv.ParseFloat(in.Receipt.Total, 64)
+		if err != nil {
+			return Signal{}, false
+		}
+		var sum float64
+		for _, item := range in.Receipt.Items {
+			price, err := strconv.ParseFloat(item.Price, 64)
+			if err != nil {
+				return Signal{}, false
+			}
+			sum += price
+		}
+		if math.Abs(sum-total) <= total*0.25+1 {
+			return Signal{}, false
+		}
+		return Signal{Score: 40, Reason: "item prices do not add up to the total", Field: "items"}, true
+	})
+}
+
+// OddRetailerName flags retailer names that are mostly not letters or that
+// repeat a character many times in a row.
+func OddRetailerName() Check {
+	return CheckFunc(func(in Input) (Signal, bool) {
+		var (
+			letters, others int
+			run, longestRun int
+			last            rune
+		)
+		for _, r := range in.Receipt.Retailer {
+			switch {
+			case unicode.IsLetter(r):
+				letters++
+			case !unicode.IsSpace(r):
+				others++
+			}
+			if r == last {
+				run++
+			} else {
+				run = 1
+			}
+			last = r
+			longestRun = max(longestRun, run)
+		}
+		if others <= letters && longestRun < 5 {
+			return Signal{}, false
+		}
+		return Signal{Score: 20, Reason: "retailer name has unusual characters"}, true
+	})
+}
+
+// maxTrackedSubmitters bounds the burst history before idle submitters are
+// pruned.
+const maxTrackedSubmitters = 10000
+
+// BurstCheck flags submitters that send more than a limit of receipts within
+// a sliding window.
+type BurstCheck struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	history map[string][]time.Time
+}
+
+func NewBurstCheck(limit int, window time.Duration) *BurstCheck {
+	return &BurstCheck{
+		limit:   limit,
+		window:  window,
+		history: make(map[string][]time.Time),
+	}
+}
+
+// Check records the submission and flags it if the submitter is over the
+// limit.
+func (b *BurstCheck) Check(in Input) (Signal, bool) {
+	if in.Submitter == "" || b.limit <= 0 {
+		return Signal{}, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	since := in.Now.Add(-b.window)
+	recent := b.history[in.Submitter][:0]
+	for _, t := range b.history[in.Submitter] {
+		if t.After(since) {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, in.Now)
+	b.history[in.Submitter] = recent
+
+	if len(b.history) > maxTrackedSubmitters {
+		for submitter, times := range b.history {
+			if !times[len(times)-1].After(since) {
+				delete(b.history, submitter)
+			}
+		}
+	}
+
+	if len(recent) <= b.limit {
+		return Signal{}, false
+	}
+	return Signal{Score: 40, Reason: "too many receipts from the same submitter"}, true
+}
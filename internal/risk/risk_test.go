@@ -0,0 +1,148 @@
+package risk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+var now = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func receipt(retailer, purchaseDate, total string, prices ...string) entities.Receipt {
+	r := entities.Receipt{
+		Retailer:     retailer,
+		PurchaseDate: purchaseDate,
+		PurchaseTime: "12:00",
+		Total:        total,
+	}
+	for _, price := range prices {
+		r.Items = append(r.Items, entities.Item{ShortDescription: "Item", Price: price})
+	}
+	return r
+}
+
+func Test_DefaultChecks(t *testing.T) {
+	testCases := map[string]struct {
+		input           entities.Receipt
+		expectedScore   int
+		expectedReasons int
+		expectHold      bool
+	}{
+		"clean receipt": {
+			input: receipt("Target", "2022-05-31", "10.00", "4.00", "6.00"),
+		},
+		"tax within tolerance": {
+			input: receipt("Target", "2022-05-31", "10.80", "4.00", "6.00"),
+		},
+		"future dated": {
+			input:           receipt("Target", "2022-06-03", "10.00", "4.00", "6.00"),
+			expectedScore:   70,
+			expectedReasons: 1,
+			expectHold:      true,
+		},
+		"future dated within tolerance": {
+			input: receipt("Target", "2022-06-02", "10.00", "4.00", "6.00"),
+		},
+		"implausible total": {
+			input:           receipt("Target", "2022-05-31", "9000.00", "9000.00"),
+			expectedScore:   40,
+			expectedReasons: 1,
+			expectHold:      true,
+		},
+		"items far off total": {
+			input:           receipt("Target", "2022-05-31", "100.00", "4.00", "6.00"),
+			expectedScore:   40,
+			expectedReasons: 1,
+			expectHold:      true,
+		},
+		"odd retailer name": {
+			input:           receipt("1234 - 5678", "2022-05-31", "10.00", "10.00"),
+			expectedScore:   20,
+			expectedReasons: 1,
+		},
+		"repeated characters in retailer name": {
+			input:           receipt("Shoppppppe", "2022-05-31", "10.00", "10.00"),
+			expectedScore:   20,
+			expectedReasons: 1,
+		},
+		"signals add up": {
+			input:           receipt("1234", "2022-05-31", "9000.00", "4.00"),
+			expectedScore:   100,
+			expectedReasons: 3,
+			expectHold:      true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			e := NewDefault(DefaultConfig())
+			assessment := e.Evaluate(Input{Receipt: tc.input, Submitter: "test", Now: now})
+			if assessment.Score != tc.expectedScore {
+				t.Errorf("unexpected score: got %d, want %d", assessment.Score, tc.expectedScore)
+			}
+			if len(assessment.Reasons) != tc.expectedReasons {
+				t.Errorf("unexpected reasons: got %v, want %d", assessment.Reasons, tc.expectedReasons)
+			}
+			if assessment.Hold != tc.expectHold {
+				t.Errorf("unexpected hold: got %t, want %t", assessment.Hold, tc.expectHold)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_implausibleFields(t *testing.T) {
+	behaviour := CheckFunc(func(in Input) (Signal, bool) {
+		return Signal{Score: 10, Reason: "suspicious behaviour"}, true
+	})
+	field := CheckFunc(func(in Input) (Signal, bool) {
+		return Signal{Score: 10, Reason: "implausible total", Field: "total"}, true
+	})
+
+	testCases := map[string]struct {
+		evaluator  Evaluator
+		expectHold bool
+	}{
+		"below threshold": {
+			evaluator: New(70, behaviour),
+		},
+		"implausible field below threshold": {
+			evaluator:  New(70, behaviour, field),
+			expectHold: true,
+		},
+		"implausible field without threshold": {
+			evaluator:  New(0, field),
+			expectHold: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			assessment := tc.evaluator.Evaluate(Input{Now: now})
+			if assessment.Hold != tc.expectHold {
+				t.Errorf("unexpected hold: got %t, want %t", assessment.Hold, tc.expectHold)
+			}
+		})
+	}
+}
+
+func Test_BurstCheck(t *testing.T) {
+	b := NewBurstCheck(3, time.Minute)
+	in := Input{Submitter: "user"}
+
+	for i, expected := range []bool{false, false, false, true, true} {
+		in.Now = now.Add(time.Duration(i) * time.Second)
+		if _, flagged := b.Check(in); flagged != expected {
+			t.Errorf("submission %d: unexpected flag: got %t, want %t", i, flagged, expected)
+		}
+	}
+
+	if _, flagged := b.Check(Input{Submitter: "other", Now: now}); flagged {
+		t.Error("other submitter flagged")
+	}
+
+	in.Now = now.Add(2 * time.Minute)
+	if _, flagged := b.Check(in); flagged {
+		t.Error("submitter still flagged after the window passed")
+	}
+}
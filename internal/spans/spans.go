@@ -0,0 +1,15 @@
+// Package spans holds helpers for OpenTelemetry spans, kept apart from
+// package tracing so that scoring can use them without depending on the
+// exporters, middleware and repositories tracing is built on.
+package spans
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fail marks span as failed with err.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,61 @@
+// Package tenants reads the loyalty programs hosted by one deployment.
+package tenants
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Tenant is a loyalty program with its own users, receipts and rewards.
+type Tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Rules is the path of the tenant's ruleset file, relative to the
+	// tenants file. Tenants without one are scored with the service's
+	// ruleset.
+	Rules string `json:"rules,omitempty"`
+}
+
+// Load reads a JSON array of tenants from path.
+func Load(path string) ([]Tenant, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ts []Tenant
+	if err := json.Unmarshal(b, &ts); err != nil {
+		return nil, fmt.Errorf("could not unmarshal tenants: %w", err)
+	}
+	if err := Validate(ts); err != nil {
+		return nil, err
+	}
+	for i := range ts {
+		if ts[i].Rules != "" && !filepath.IsAbs(ts[i].Rules) {
+			ts[i].Rules = filepath.Join(filepath.Dir(path), ts[i].Rules)
+		}
+	}
+	return ts, nil
+}
+
+// Validate checks that every tenant has a well formed, unique ID.
+func Validate(ts []Tenant) error {
+	if len(ts) == 0 {
+		return fmt.Errorf("no tenants defined")
+	}
+	seen := make(map[string]bool, len(ts))
+	for i, t := range ts {
+		if !idPattern.MatchString(t.ID) {
+			return fmt.Errorf("tenant %d: invalid id %q", i, t.ID)
+		}
+		if seen[t.ID] {
+			return fmt.Errorf("tenant %d: duplicate id %q", i, t.ID)
+		}
+		seen[t.ID] = true
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package tenants
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_Load(t *testing.T) {
+	ts, err := Load("testdata/tenants.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(ts) != 2 || ts[0].ID != "acme" || ts[1].Rules == "" {
+		t.Fatalf("unexpected tenants: %+v", ts)
+	}
+	if _, err := os.Stat(ts[1].Rules); err != nil {
+		t.Errorf("rules path not resolved against the tenants file: %s", err.Error())
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	testCases := map[string]struct {
+		tenants     []Tenant
+		expectError bool
+	}{
+		"valid": {
+			tenants: []Tenant{{ID: "acme"}, {ID: "brunch-club"}},
+		},
+		"empty": {
+			expectError: true,
+		},
+		"missing id": {
+			tenants:     []Tenant{{Name: "Acme"}},
+			expectError: true,
+		},
+		"invalid id": {
+			tenants:     []Tenant{{ID: "Acme Rewards"}},
+			expectError: true,
+		},
+		"duplicate id": {
+			tenants:     []Tenant{{ID: "acme"}, {ID: "acme"}},
+			expectError: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			err := Validate(tc.tenants)
+			if tc.expectError && err == nil {
+				t.Error("expected error but did not get one")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		})
+	}
+}
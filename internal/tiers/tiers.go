@@ -0,0 +1,84 @@
+package tiers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+// qualifyingMonths is the rolling window of receipts that count towards a
+// user's tier.
+const qualifyingMonths = 12
+
+// Tier multiplies the base points of receipts from users whose qualifying
+// points reach Threshold.
+type Tier struct {
+	Name      string `json:"name"`
+	Threshold int    `json:"threshold"`
+	// MultiplierPercent scales base points, so 150 awards one and a half
+	// times the base points.
+	MultiplierPercent int `json:"multiplierPercent"`
+}
+
+// Apply scales base points by the tier multiplier, rounding down.
+func (t Tier) Apply(basePoints int) int {
+	return basePoints * t.MultiplierPercent / 100
+}
+
+// Tiers is a list of tiers ordered by ascending Threshold. The first tier
+// must have a zero Threshold.
+type Tiers []Tier
+
+func Default() Tiers {
+	return Tiers{
+		{Name: "Bronze", Threshold: 0, MultiplierPercent: 100},
+		{Name: "Silver", Threshold: 1000, MultiplierPercent: 125},
+		{Name: "Gold", Threshold: 5000, MultiplierPercent: 150},
+	}
+}
+
+// For returns the highest tier reached with the qualifying points and the
+// tier after it, or nil if it is the highest tier.
+func (ts Tiers) For(qualifyingPoints int) (Tier, *Tier) {
+	current := 0
+	for i, tier := range ts {
+		if qualifyingPoints >= tier.Threshold {
+			current = i
+		}
+	}
+	if current+1 < len(ts) {
+		return ts[current], &ts[current+1]
+	}
+	return ts[current], nil
+}
+
+// Named returns the tier called name, ignoring case.
+func (ts Tiers) Named(name string) (Tier, bool) {
+	for _, tier := range ts {
+		if strings.EqualFold(tier.Name, name) {
+			return tier, true
+		}
+	}
+	return Tier{}, false
+}
+
+// QualifyingPoints sums the base points of records purchased in the twelve
+// months up to now, ignoring records that are not credited.
+func QualifyingPoints(records []entities.ReceiptRecord, now time.Time) int {
+	var total int
+	since := now.AddDate(0, -qualifyingMonths, 0)
+	for _, record := range records {
+		if !record.Credited() {
+			continue
+		}
+		purchasedAt, err := record.PurchasedAt()
+		if err != nil {
+			continue
+		}
+		if purchasedAt.After(since) && !purchasedAt.After(now) {
+			total += record.BasePoints
+		}
+	}
+	return total
+}
@@ -0,0 +1,94 @@
+package tiers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+)
+
+func Test_For(t *testing.T) {
+	testCases := map[string]struct {
+		qualifyingPoints int
+		expectedTier     string
+		expectedNext     string
+	}{
+		"no points": {
+			expectedTier: "Bronze",
+			expectedNext: "Silver",
+		},
+		"just below silver": {
+			qualifyingPoints: 999,
+			expectedTier:     "Bronze",
+			expectedNext:     "Silver",
+		},
+		"silver threshold": {
+			qualifyingPoints: 1000,
+			expectedTier:     "Silver",
+			expectedNext:     "Gold",
+		},
+		"gold has no next tier": {
+			qualifyingPoints: 12000,
+			expectedTier:     "Gold",
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			tier, next := Default().For(tc.qualifyingPoints)
+			if tier.Name != tc.expectedTier {
+				t.Errorf("unexpected tier: got %s, want %s", tier.Name, tc.expectedTier)
+			}
+			var nextName string
+			if next != nil {
+				nextName = next.Name
+			}
+			if nextName != tc.expectedNext {
+				t.Errorf("unexpected next tier: got %q, want %q", nextName, tc.expectedNext)
+			}
+		})
+	}
+}
+
+func Test_Apply(t *testing.T) {
+	testCases := []struct {
+		tier           Tier
+		basePoints     int
+		expectedPoints int
+	}{
+		{tier: Default()[0], basePoints: 28, expectedPoints: 28},
+		{tier: Default()[1], basePoints: 28, expectedPoints: 35},
+		{tier: Default()[2], basePoints: 109, expectedPoints: 163},
+	}
+
+	for _, tc := range testCases {
+		points := tc.tier.Apply(tc.basePoints)
+		if points != tc.expectedPoints {
+			t.Errorf("%s: unexpected points: got %d, want %d", tc.tier.Name, points, tc.expectedPoints)
+		}
+	}
+}
+
+func Test_QualifyingPoints(t *testing.T) {
+	record := func(purchaseDate string, basePoints int) entities.ReceiptRecord {
+		return entities.ReceiptRecord{
+			Receipt:    entities.Receipt{PurchaseDate: purchaseDate, PurchaseTime: "12:00"},
+			Points:     basePoints * 2,
+			BasePoints: basePoints,
+		}
+	}
+	records := []entities.ReceiptRecord{
+		record("2021-05-31", 1000),
+		record("2021-06-02", 100),
+		record("2022-05-31", 20),
+		record("2022-06-02", 5000),
+		record("2022-05-30", 300),
+	}
+	voidedAt := time.Date(2022, 5, 31, 0, 0, 0, 0, time.UTC)
+	records[4].VoidedAt = &voidedAt
+
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	if points := QualifyingPoints(records, now); points != 120 {
+		t.Errorf("unexpected qualifying points: got %d, want %d", points, 120)
+	}
+}
@@ -0,0 +1,179 @@
+// Package tlsreload serves TLS with certificates that are reloaded from
+// disk when they change, so they can be rotated without a restart.
+package tlsreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often Watch checks the files for changes.
+const DefaultWatchInterval = 10 * time.Second
+
+// Files names the PEM files TLS is configured from.
+type Files struct {
+	Cert string
+	Key  string
+	// ClientCA, when set, is the CA bundle client certificates are verified
+	// against.
+	ClientCA string
+	// RequireClientCert refuses connections without a verified client
+	// certificate. Otherwise clients may connect without one.
+	RequireClientCert bool
+}
+
+// Reloader holds the current certificate and client CAs.
+type Reloader struct {
+	files  Files
+	logger *slog.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	// modTimes is when each file was last changed as of the last load.
+	modTimes map[string]time.Time
+}
+
+// New loads the files, failing if any cannot be used.
+func New(files Files, logger *slog.Logger) (*Reloader, error) {
+	if files.Cert == "" || files.Key == "" {
+		return nil, errors.New("a certificate and key are required")
+	}
+	if files.RequireClientCert && files.ClientCA == "" {
+		return nil, errors.New("requiring client certificates needs a client CA")
+	}
+	r := &Reloader{files: files, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. The previous certificate and CAs stay in
+// use if they cannot be loaded.
+func (r *Reloader) Reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.files.Cert, r.files.Key)
+	if err != nil {
+		return fmt.Errorf("could not load certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.files.ClientCA != "" {
+		b, err := os.ReadFile(r.files.ClientCA)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return fmt.Errorf("no certificates found in %s", r.files.ClientCA)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTimes = modTimes
+	return nil
+}
+
+// Config returns a TLS config that serves the current certificate and
+// verifies clients against the current CAs on every handshake. It offers
+// HTTP/2 and HTTP/1.1 itself, since an http.Server only adds them to its
+// own copy of the config.
+func (r *Reloader) Config() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: r.certificate,
+	}
+	if r.files.ClientCA == "" {
+		return cfg
+	}
+	// The client CAs can only change with the whole config, so each
+	// handshake gets a copy of this one with the current CAs.
+	base := cfg.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		clientCfg := base.Clone()
+		clientCfg.ClientCAs = r.clientCAs
+		clientCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if r.files.RequireClientCert {
+			clientCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		return clientCfg, nil
+	}
+	return cfg
+}
+
+func (r *Reloader) certificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch reloads the files whenever one of them changes, checking every
+// interval until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				r.logger.Error("error reloading TLS certificates", "error", err.Error())
+				continue
+			}
+			r.logger.Info("reloaded TLS certificates")
+		}
+	}
+}
+
+// changed reports whether any file was modified since the last load.
+func (r *Reloader) changed() bool {
+	modTimes, err := r.stat()
+	if err != nil {
+		// A file being replaced may briefly be missing; try again later.
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for path, t := range modTimes {
+		if !t.Equal(r.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, 3)
+	for _, path := range []string{r.files.Cert, r.files.Key, r.files.ClientCA} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[path] = info.ModTime()
+	}
+	return modTimes, nil
+}
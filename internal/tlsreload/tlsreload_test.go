@@ -0,0 +1,339 @@
+package tlsreload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// authority is a CA generated for a test.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T, name string) *authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key signed by the CA.
+func (a *authority) issue(t *testing.T, serial int64, subject pkix.Name, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (a *authority) clientCert(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+	certPEM, keyPEM := a.issue(t, 100, pkix.Name{CommonName: name}, x509.ExtKeyUsageClientAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func writeFile(t *testing.T, path string, b []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve starts an HTTPS server with the reloader's config that responds
+// with the common name of the verified client certificate, if any.
+func serve(t *testing.T, r *Reloader) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if len(req.TLS.VerifiedChains) > 0 {
+			w.Write([]byte(req.TLS.VerifiedChains[0][0].Subject.CommonName))
+		}
+	})}
+	srv.TLSConfig = r.Config()
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	go srv.ServeTLS(ln, "", "")
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+// get makes a request on a new connection and returns the serial of the
+// server's certificate and the response body.
+func get(url string, roots *x509.CertPool, clientCerts ...tls.Certificate) (int64, string, error) {
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: clientCerts},
+		DisableKeepAlives: true,
+	}}
+	res, err := client.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, "", err
+	}
+	return res.TLS.PeerCertificates[0].SerialNumber.Int64(), string(body), nil
+}
+
+func Test_Reloader_clientCertificates(t *testing.T) {
+	ca := newAuthority(t, "test CA")
+	other := newAuthority(t, "other CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	dir := t.TempDir()
+	files := Files{
+		Cert:     filepath.Join(dir, "server.pem"),
+		Key:      filepath.Join(dir, "server-key.pem"),
+		ClientCA: filepath.Join(dir, "clients.pem"),
+	}
+	certPEM, keyPEM := ca.issue(t, 1, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, files.Cert, certPEM)
+	writeFile(t, files.Key, keyPEM)
+	writeFile(t, files.ClientCA, ca.pem)
+
+	testCases := map[string]struct {
+		require      bool
+		clientCerts  []tls.Certificate
+		expectError  bool
+		expectedName string
+	}{
+		"optional without certificate": {},
+		"optional with certificate": {
+			clientCerts:  []tls.Certificate{ca.clientCert(t, "partner-a")},
+			expectedName: "partner-a",
+		},
+		"required without certificate": {
+			require:     true,
+			expectError: true,
+		},
+		"required with certificate": {
+			require:      true,
+			clientCerts:  []tls.Certificate{ca.clientCert(t, "partner-a")},
+			expectedName: "partner-a",
+		},
+		"certificate from another CA": {
+			require:     true,
+			clientCerts: []tls.Certificate{other.clientCert(t, "intruder")},
+			expectError: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			f := files
+			f.RequireClientCert = tc.require
+			r, err := New(f, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			url := serve(t, r)
+
+			_, name, err := get(url, roots, tc.clientCerts...)
+			if tc.expectError {
+				if err == nil {
+					t.Error("expected error but did not get one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if name != tc.expectedName {
+				t.Errorf("unexpected client: got %q, expected %q", name, tc.expectedName)
+			}
+		})
+	}
+}
+
+func Test_Reloader_http2(t *testing.T) {
+	ca := newAuthority(t, "test CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	dir := t.TempDir()
+	files := Files{Cert: filepath.Join(dir, "server.pem"), Key: filepath.Join(dir, "server-key.pem")}
+	certPEM, keyPEM := ca.issue(t, 1, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, files.Cert, certPEM)
+	writeFile(t, files.Key, keyPEM)
+	writeFile(t, filepath.Join(dir, "clients.pem"), ca.pem)
+
+	testCases := map[string]struct {
+		clientCA string
+	}{
+		"server certificate only": {},
+		"client certificates": {
+			clientCA: filepath.Join(dir, "clients.pem"),
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			f := files
+			f.ClientCA = tc.clientCA
+			r, err := New(f, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			url := serve(t, r)
+
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{ca.clientCert(t, "partner-a")}},
+				ForceAttemptHTTP2: true,
+			}}
+			res, err := client.Get(url)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			res.Body.Close()
+			if res.ProtoMajor != 2 {
+				t.Errorf("unexpected protocol: got %s, expected HTTP/2.0", res.Proto)
+			}
+		})
+	}
+}
+
+func Test_Reloader_Watch(t *testing.T) {
+	ca := newAuthority(t, "test CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	dir := t.TempDir()
+	files := Files{Cert: filepath.Join(dir, "server.pem"), Key: filepath.Join(dir, "server-key.pem")}
+	certPEM, keyPEM := ca.issue(t, 1, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, files.Cert, certPEM)
+	writeFile(t, files.Key, keyPEM)
+
+	r, err := New(files, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, r)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	if serial, _, err := get(url, roots); err != nil || serial != 1 {
+		t.Fatalf("unexpected first certificate: serial %d, error %v", serial, err)
+	}
+
+	// A broken certificate is not loaded.
+	future := time.Now().Add(time.Minute)
+	writeFile(t, files.Cert, []byte("not a certificate"))
+	os.Chtimes(files.Cert, future, future)
+	if err := r.Reload(); err == nil {
+		t.Error("expected error reloading a broken certificate")
+	}
+	if serial, _, err := get(url, roots); err != nil || serial != 1 {
+		t.Fatalf("broken certificate replaced the working one: serial %d, error %v", serial, err)
+	}
+
+	certPEM, keyPEM = ca.issue(t, 2, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, files.Cert, certPEM)
+	writeFile(t, files.Key, keyPEM)
+	future = future.Add(time.Minute)
+	os.Chtimes(files.Cert, future, future)
+	os.Chtimes(files.Key, future, future)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		serial, _, err := get(url, roots)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if serial == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_New(t *testing.T) {
+	testCases := map[string]struct {
+		files Files
+	}{
+		"no certificate": {
+			files: Files{Key: "key.pem"},
+		},
+		"missing files": {
+			files: Files{Cert: "missing.pem", Key: "missing-key.pem"},
+		},
+		"required client certificate without CA": {
+			files: Files{Cert: "cert.pem", Key: "key.pem", RequireClientCert: true},
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			if _, err := New(tc.files, slog.Default()); err == nil {
+				t.Error("expected error but did not get one")
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Package tracing records OpenTelemetry spans for requests and the
+// repository operations they make.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"github.com/gpayne44/fetch-challenge/internal/spans"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+
+	instrumentationName = "github.com/gpayne44/fetch-challenge/internal/tracing"
+)
+
+// Config selects where spans are exported.
+type Config struct {
+	// Exporter is one of ExporterNone, ExporterOTLP, ExporterStdout or
+	// ExporterFile.
+	Exporter string
+	// File is where ExporterFile writes spans, one JSON object each.
+	File string
+	// ServiceName identifies the service in exported spans.
+	ServiceName string
+	// SampleRatio is the fraction of new traces recorded. Requests that
+	// arrive with a sampled trace context are always recorded.
+	SampleRatio float64
+}
+
+// Setup installs the W3C trace context propagator and, unless the exporter
+// is ExporterNone, a tracer provider exporting spans as cfg describes. The
+// returned function flushes and stops the exporter.
+//
+// ExporterOTLP sends spans over HTTP, configured by the standard
+// OTEL_EXPORTER_OTLP_* environment variables.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+		err      error
+	)
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, errors.New("the file exporter needs a file")
+		}
+		var f *os.File
+		f, err = os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		closer = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio %v is not between 0 and 1", cfg.SampleRatio)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// Start starts a span as a child of any span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Middleware continues the trace in the request's traceparent header, or
+// starts a new one, in a server span named after the route. The trace ID is
+// added to the request's log lines.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		route := logging.Route(r)
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+		if sc := span.SpanContext(); sc.IsValid() {
+			logging.With(ctx, "trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+		}
+
+		rec := logging.NewResponseRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.Status))
+		if rec.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.Status))
+		}
+	})
+}
+
+// Observer returns a repository observer that records each operation as a
+// span under the span in ctx. Missing records are not failures.
+func Observer(ctx context.Context) repositories.Observer {
+	return func(operation string) func(error) {
+		_, span := Start(ctx, "repository."+operation, attribute.String("repository.operation", operation))
+		return func(err error) {
+			if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				spans.Fail(span, err)
+			}
+			span.End()
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gpayne44/fetch-challenge/internal/entities"
+	"github.com/gpayne44/fetch-challenge/internal/logging"
+	"github.com/gpayne44/fetch-challenge/internal/repositories"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record installs a tracer provider that keeps every span it ends.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+	return recorder
+}
+
+func Test_Middleware(t *testing.T) {
+	const (
+		traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+		traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+	)
+
+	testCases := map[string]struct {
+		traceparent   string
+		status        int
+		expectTraceID string
+		expectFailure bool
+	}{
+		"new trace": {
+			status: http.StatusOK,
+		},
+		"continues caller's trace": {
+			traceparent:   traceparent,
+			status:        http.StatusNotFound,
+			expectTraceID: traceID,
+		},
+		"server error": {
+			status:        http.StatusInternalServerError,
+			expectFailure: true,
+		},
+	}
+
+	for caseName, tc := range testCases {
+		t.Run(caseName, func(t *testing.T) {
+			recorder := record(t)
+
+			r := mux.NewRouter()
+			r.HandleFunc("/receipts/{id:[a-f]{3}-[0-9]{3}}/points", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			})
+			handler := logging.Routes(r)(Middleware(r))
+
+			req := httptest.NewRequest(http.MethodGet, "/receipts/abc-123/points", nil)
+			if tc.traceparent != "" {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("unexpected span count: got %d, expected 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != "GET /receipts/{id}/points" {
+				t.Errorf("unexpected span name %q", span.Name())
+			}
+			if tc.expectTraceID != "" && span.SpanContext().TraceID().String() != tc.expectTraceID {
+				t.Errorf("unexpected trace id: got %s, expected %s", span.SpanContext().TraceID(), tc.expectTraceID)
+			}
+			if failed := span.Status().Code == codes.Error; failed != tc.expectFailure {
+				t.Errorf("unexpected span status %v", span.Status())
+			}
+		})
+	}
+}
+
+func Test_Observer(t *testing.T) {
+	recorder := record(t)
+
+	ctx, parent := Start(context.Background(), "request")
+	repo := repositories.WithObserver(repositories.New(), Observer(ctx))
+	if _, err := repo.GetReceipt(uuid.New()); !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := repo.StoreReceipt(entities.ReceiptRecord{Points: 10}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("unexpected span count: got %d, expected 3", len(spans))
+	}
+	for i, name := range []string{"repository.GetReceipt", "repository.StoreReceipt"} {
+		span := spans[i]
+		if span.Name() != name {
+			t.Errorf("unexpected span name: got %q, expected %q", span.Name(), name)
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the request span", name)
+		}
+		if span.Status().Code == codes.Error {
+			t.Errorf("%s marked failed", name)
+		}
+	}
+}